@@ -10,6 +10,8 @@ env_variables:
   SESSION_SECRET: _SESSION_SECRET
   ENCRYPTION_SECRET: _ENCRYPTION_SECRET
   REDIS_SESSION_DB: _REDIS_SESSION_DB
+  LINK_TOKEN_SECRET: _LINK_TOKEN_SECRET
+  UAC_HASH_SECRET: _UAC_HASH_SECRET
   GIN_MODE: release
 
 vpc_access_connector:
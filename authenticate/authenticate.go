@@ -1,14 +1,21 @@
 package authenticate
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
 	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+	"github.com/ONSdigital/blaise-cawi-portal/circuitbreaker"
+	"github.com/ONSdigital/blaise-cawi-portal/fieldperiod"
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
 	"github.com/ONSdigital/blaise-cawi-portal/languagemanager"
+	"github.com/ONSdigital/blaise-cawi-portal/sessionregistry"
+	"github.com/ONSdigital/blaise-cawi-portal/throttle"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
 	"github.com/ONSdigital/blaise-cawi-portal/utils"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -17,10 +24,14 @@ import (
 )
 
 const (
-	SESSION_TIMEOUT_KEY = "session_timeout"
-	JWT_TOKEN_KEY       = "jwt_token"
-	SESSION_VALID_KEY   = "session_valid"
-	ISSUER              = "social-surveys-web-portal"
+	SESSION_TIMEOUT_KEY           = "session_timeout"
+	SAVE_SESSION_ON_TIMEOUT_KEY   = "save_session_on_timeout"
+	SAVE_SESSION_ON_QUIT_KEY      = "save_session_on_quit"
+	DELETE_SESSION_ON_TIMEOUT_KEY = "delete_session_on_timeout"
+	DELETE_SESSION_ON_QUIT_KEY    = "delete_session_on_quit"
+	JWT_TOKEN_KEY                 = "jwt_token"
+	SESSION_VALID_KEY             = "session_valid"
+	ISSUER                        = "social-surveys-web-portal"
 )
 
 var (
@@ -36,6 +47,42 @@ var (
 		"english": "We were unable to process your request, please try again",
 		"welsh":   "Ni allwn brosesu eich cais, rhowch gynnig arall arni",
 	}
+	UAC_CHECK_UNAVAILABLE_ERR = map[string]string{
+		"english": "Sorry, we cannot check your access code at the moment. Try again in a few minutes",
+		"welsh":   "Mae'n ddrwg gennym, ni allwn wirio eich cod mynediad ar hyn o bryd. Rhowch gynnig arall arni ymhen ychydig funudau",
+	}
+	SERVICE_PROBLEM_ERR = map[string]string{
+		"english": "Sorry, there is a problem with this service. Try again later",
+		"welsh":   "Mae'n ddrwg gennym, mae problem gyda'r gwasanaeth hwn. Rhowch gynnig arall arni yn nes ymlaen",
+	}
+	INVALID_CHARACTERS_ERR = map[string]string{
+		"english": "Enter your access code using only the letters and numbers shown on your letter",
+		"welsh":   "Rhowch eich cod mynediad gan ddefnyddio'r llythrennau a'r rhifau sydd ar eich llythyr yn unig",
+	}
+	INVALID_CHECK_DIGIT_ERR = map[string]string{
+		"english": "Access code not recognised. Check you have entered the code exactly as it appears on your letter",
+		"welsh":   "Nid yw'r cod mynediad yn cael ei gydnabod. Gwnewch yn siŵr eich bod wedi rhoi'r cod yn union fel y mae'n ymddangos ar eich llythyr",
+	}
+	TOO_MANY_ATTEMPTS_ERR = map[string]string{
+		"english": "Too many attempts to enter an access code. Try again in %d minutes",
+		"welsh":   "Gormod o ymdrechion i roi cod mynediad. Rhowch gynnig arall arni ymhen %d munud",
+	}
+	INVALID_LINK_ERR = map[string]string{
+		"english": "This link has expired or is not valid. Enter the access code from your letter",
+		"welsh":   "Mae'r ddolen hon wedi dod i ben neu nid yw'n ddilys. Rhowch y cod mynediad o'ch llythyr",
+	}
+	USED_LINK_ERR = map[string]string{
+		"english": "This link has already been used. Enter the access code from your letter",
+		"welsh":   "Mae'r ddolen hon eisoes wedi cael ei defnyddio. Rhowch y cod mynediad o'ch llythyr",
+	}
+	SESSION_IN_USE_ERR = map[string]string{
+		"english": "This access code is already being used in another browser or device. Log out of the study there, or try again later",
+		"welsh":   "Mae'r cod mynediad hwn eisoes yn cael ei ddefnyddio mewn porwr neu ddyfais arall. Allgofnodwch o'r astudiaeth yno, neu rhowch gynnig arall arni yn nes ymlaen",
+	}
+	SESSION_REPLACED_ERR = map[string]string{
+		"english": "You have been logged out because your access code was used in another browser or device",
+		"welsh":   "Rydych wedi cael eich allgofnodi oherwydd bod eich cod mynediad wedi cael ei ddefnyddio mewn porwr neu ddyfais arall",
+	}
 )
 
 //Generate mocks by running "go generate ./..."
@@ -43,8 +90,11 @@ var (
 type AuthInterface interface {
 	AuthenticatedWithUac(*gin.Context)
 	Login(*gin.Context, sessions.Session)
+	LinkLogin(*gin.Context, sessions.Session, string)
 	Logout(*gin.Context, sessions.Session)
 	HasSession(*gin.Context) (bool, *UACClaims)
+	LifetimeEnded(*gin.Context) (bool, *UACClaims)
+	SessionEnded(*gin.Context, sessions.Session, *UACClaims)
 	NotAuthWithError(*gin.Context, string)
 	RefreshToken(*gin.Context, sessions.Session, *UACClaims)
 }
@@ -57,6 +107,20 @@ type Auth struct {
 	UacKind         string
 	CSRFManager     csrf.CSRFManager
 	LanguageManager languagemanager.LanguageManagerInterface
+	Throttle        throttle.ThrottleInterface
+	UacValidators   map[string]UacValidatorInterface
+	LinkTokens      LinkTokenCryptoInterface
+	LinkTokenStore  kvstore.StoreInterface
+	Sessions        sessionregistry.RegistryInterface
+	// FieldPeriods, when set, refuses logins to instruments outside of their
+	// field period
+	FieldPeriods fieldperiod.FieldPeriodsInterface
+	// SessionMaxLifetime is how long a session can last, however active it
+	// is, unless the instrument has its own limit in
+	// InstrumentSessionMaxLifetimes, keyed by instrument name or prefix*.
+	// Zero means no limit.
+	SessionMaxLifetime            time.Duration
+	InstrumentSessionMaxLifetimes map[string]time.Duration
 }
 
 func (auth *Auth) AuthenticatedWithUac(context *gin.Context) {
@@ -68,12 +132,29 @@ func (auth *Auth) AuthenticatedWithUac(context *gin.Context) {
 		return
 	}
 
-	_, err := auth.JWTCrypto.DecryptJWT(jwtToken)
+	claim, err := auth.JWTCrypto.DecryptJWT(jwtToken)
 	if err != nil {
 		log.Println(err)
 		auth.notAuth(context)
 		return
 	}
+
+	if claim.LifetimeEnded(time.Now()) {
+		auth.SessionEnded(context, session, claim)
+		return
+	}
+
+	active, err := auth.sessionActive(claim)
+	if err != nil {
+		auth.Logger.Error("Could not check session is active", append(claim.LogFields(), zap.Error(err))...)
+		auth.notAuth(context)
+		return
+	}
+	if !active {
+		auth.Logger.Info("Session no longer active", append(utils.GetRequestSource(context), claim.LogFields()...)...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(SESSION_REPLACED_ERR, context))
+		return
+	}
 	context.Next()
 }
 
@@ -89,13 +170,62 @@ func (auth *Auth) HasSession(context *gin.Context) (bool, *UACClaims) {
 	if err != nil || claim == nil {
 		return false, nil
 	}
+	if claim.LifetimeEnded(time.Now()) {
+		return false, nil
+	}
+	if active, err := auth.sessionActive(claim); err != nil || !active {
+		return false, nil
+	}
+	return true, claim
+}
+
+// LifetimeEnded reports whether the user has a session that has been ended
+// by reaching its maximum lifetime
+func (auth *Auth) LifetimeEnded(context *gin.Context) (bool, *UACClaims) {
+	session := sessions.DefaultMany(context, "user_session")
+	jwtToken := session.Get(JWT_TOKEN_KEY)
+	if jwtToken == nil {
+		return false, nil
+	}
+
+	claim, err := auth.JWTCrypto.DecryptJWT(jwtToken)
+	if err != nil || claim == nil || !claim.LifetimeEnded(time.Now()) {
+		return false, nil
+	}
 	return true, claim
 }
 
+// SessionEnded logs the user out of a session that has reached its maximum
+// lifetime and tells them why
+func (auth *Auth) SessionEnded(context *gin.Context, session sessions.Session, claim *UACClaims) {
+	auth.Logger.Info("Session reached maximum lifetime", append(utils.GetRequestSource(context), claim.LogFields()...)...)
+	saved, lost := TimeoutAnswers(session)
+	auth.revokeSession(session)
+
+	session.Clear()
+	session.Options(sessions.Options{MaxAge: -1})
+	if err := session.Save(); err != nil {
+		auth.Logger.Error("Failed to clear ended session", zap.Error(err))
+	}
+	if err := auth.clearSessionValidation(context); err != nil {
+		auth.Logger.Error("Failed to clear ended session validation", zap.Error(err))
+	}
+
+	context.HTML(http.StatusUnauthorized, "session_ended.tmpl", gin.H{
+		"lifetime": claim.MaxLifetimeMinutes(),
+		"saved":    saved,
+		"lost":     lost,
+		"welsh":    auth.LanguageManager.IsWelsh(context),
+	})
+	context.Abort()
+}
+
 func (auth *Auth) Login(context *gin.Context, session sessions.Session) {
-	var uacLength = 12
-	uac := context.PostForm("uac")
-	uac = strings.ReplaceAll(uac, " ", "")
+	if auth.throttled(context) {
+		return
+	}
+
+	uacKind, uac := auth.detectUacKind(context.PostForm("uac"))
 
 	if uac == "" {
 		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
@@ -104,18 +234,93 @@ func (auth *Auth) Login(context *gin.Context, session sessions.Session) {
 		return
 	}
 
-	if auth.isUac16() {
-		uacLength = 16
+	if uacKind == "" {
+		logFields := append(utils.GetRequestSource(context), zap.String("Reason", "Invalid UAC length"))
+		if acceptedUacKinds := auth.acceptedUacKinds(); len(acceptedUacKinds) == 1 {
+			logFields = append(logFields, zap.Int("UACLength", UAC_LENGTHS[acceptedUacKinds[0]]))
+		}
+		auth.Logger.Info("Failed auth", append(logFields, zap.Int("EnteredLength", len(uac)))...)
+		auth.NotAuthWithError(context, auth.uacError(context))
+		return
 	}
 
-	if len(uac) != uacLength {
+	if err := auth.UacValidators[uacKind].Validate(uac); err != nil {
 		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
-			zap.String("Reason", "Invalid UAC length"), zap.Int("UACLength", uacLength))...)
-		auth.NotAuthWithError(context, auth.uacError(context))
+			zap.String("Reason", uacValidationReason(err)), zap.String("UACKind", uacKind))...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(uacValidationError(err), context))
 		return
 	}
 
-	uacInfo, err := auth.BusApi.GetUacInfo(uac)
+	auth.authenticateUac(context, session, uac)
+}
+
+// LinkLogin logs in with a one-time link, such as the one in the QR code on a
+// letter, in place of the UAC being typed in. Each link can only be used to
+// log in once: it is claimed while the login is attempted, so two at once
+// cannot both succeed, and released again if the login fails so the
+// respondent can retry.
+func (auth *Auth) LinkLogin(context *gin.Context, session sessions.Session, linkToken string) {
+	if auth.throttled(context) {
+		return
+	}
+
+	if auth.LinkTokens == nil {
+		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Link login not configured"))...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INVALID_LINK_ERR, context))
+		return
+	}
+
+	link, err := auth.LinkTokens.DecryptLinkToken(linkToken)
+	if err != nil {
+		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Invalid link token"), zap.Error(err))...)
+		auth.Throttle.RecordFailure(context)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INVALID_LINK_ERR, context))
+		return
+	}
+
+	ttl := time.Until(link.ExpiresAt)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	unused, err := auth.LinkTokenStore.SetNX(LinkTokenKey(link.ID), "used", ttl)
+	if err != nil {
+		auth.Logger.Error("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Could not claim link token"),
+			zap.String("LinkTokenID", link.ID),
+			zap.Error(err),
+		)...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
+		return
+	}
+	if !unused {
+		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Link token already used"), zap.String("LinkTokenID", link.ID))...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(USED_LINK_ERR, context))
+		return
+	}
+
+	if auth.authenticateUac(context, session, link.UAC) {
+		return
+	}
+	if err := auth.LinkTokenStore.Del(LinkTokenKey(link.ID)); err != nil {
+		auth.Logger.Error("Could not release link token after failed login", append(utils.GetRequestSource(context),
+			zap.String("LinkTokenID", link.ID),
+			zap.Error(err),
+		)...)
+	}
+}
+
+// authenticateUac looks up a well formed UAC and, if it is for a live case,
+// starts the user's session and sends them on to their instrument. It reports
+// whether they were logged in; if not, the response has already been written.
+func (auth *Auth) authenticateUac(context *gin.Context, session sessions.Session, uac string) bool {
+	uacInfo, err := auth.BusApi.GetUacInfo(context.Request.Context(), uac)
+	if err != nil && !isUacNotFound(err) {
+		auth.uacLookupFailed(context, err)
+		return false
+	}
 	if err != nil || uacInfo.InvalidCase() {
 		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
 			zap.String("Reason", "Access code not recognised"),
@@ -123,11 +328,16 @@ func (auth *Auth) Login(context *gin.Context, session sessions.Session) {
 			zap.String("CaseID", uacInfo.CaseID),
 			zap.Error(err),
 		)...)
+		auth.Throttle.RecordFailure(context)
 		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(NOT_RECOGNISED_ERR, context))
-		return
+		return false
+	}
+
+	if !auth.inFieldPeriod(context, uacInfo) {
+		return false
 	}
 
-	instrumentSettings, err := auth.BlaiseRestApi.GetInstrumentSettings(uacInfo.InstrumentName)
+	instrumentSettings, err := auth.BlaiseRestApi.GetInstrumentSettings(context.Request.Context(), uacInfo.InstrumentName)
 	if err != nil {
 		if err == blaiserestapi.InstrumentNotFoundError {
 			auth.Logger.Warn("Failed auth", append(utils.GetRequestSource(context),
@@ -138,7 +348,7 @@ func (auth *Auth) Login(context *gin.Context, session sessions.Session) {
 				zap.Error(err),
 			)...)
 			auth.InstrumentNotInstalledError(context)
-			return
+			return false
 		}
 		auth.Logger.Error("Failed auth", append(utils.GetRequestSource(context),
 			zap.String("Reason", "Could not get instrument settings"),
@@ -147,26 +357,66 @@ func (auth *Auth) Login(context *gin.Context, session sessions.Session) {
 			zap.Error(err),
 		)...)
 		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
-		return
+		return false
 	}
 
-	sessionTimeout := instrumentSettings.StrictInterviewing().SessionTimeout
+	caseStatus, err := auth.BlaiseRestApi.GetCaseStatus(context.Request.Context(), uacInfo.InstrumentName, uacInfo.CaseID)
+	if err != nil {
+		auth.Logger.Warn("Could not get case status, continuing with login", append(utils.GetRequestSource(context),
+			zap.String("InstrumentName", uacInfo.InstrumentName),
+			zap.String("CaseID", uacInfo.CaseID),
+			zap.Error(err),
+		)...)
+	} else if caseStatus.Completed() {
+		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Case already completed"),
+			zap.String("InstrumentName", uacInfo.InstrumentName),
+			zap.String("CaseID", uacInfo.CaseID),
+			zap.Int("Outcome", caseStatus.Outcome),
+		)...)
+		auth.CaseAlreadyCompleted(context)
+		return false
+	}
+
+	strictInterviewing := instrumentSettings.StrictInterviewing()
+	sessionTimeout := strictInterviewing.SessionTimeout
 	if sessionTimeout == 0 {
 		sessionTimeout = DefaultAuthTimeout
 	}
-	signedToken, err := auth.JWTCrypto.EncryptJWT(uac, &uacInfo, sessionTimeout)
+	signedToken, claim, err := auth.JWTCrypto.EncryptJWT(uac, &uacInfo, sessionTimeout,
+		auth.sessionMaxLifetime(uacInfo.InstrumentName))
 	if err != nil {
 		auth.Logger.Error("Failed to Encrypt JWT", zap.Error(err))
 		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
-		return
+		return false
+	}
+
+	registered, err := auth.Sessions.Register(claim.UacRef, claim.Id, sessionLifetime(sessionTimeout))
+	if err != nil {
+		auth.Logger.Error("Failed to register session", append(claim.LogFields(), zap.Error(err))...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
+		return false
+	}
+	if !registered {
+		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Access code already in use"),
+			zap.String("InstrumentName", uacInfo.InstrumentName),
+			zap.String("CaseID", uacInfo.CaseID),
+		)...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(SESSION_IN_USE_ERR, context))
+		return false
 	}
 
 	session.Set(JWT_TOKEN_KEY, signedToken)
 	session.Set(SESSION_TIMEOUT_KEY, sessionTimeout)
+	session.Set(SAVE_SESSION_ON_TIMEOUT_KEY, strictInterviewing.SaveSessionOnTimeout)
+	session.Set(SAVE_SESSION_ON_QUIT_KEY, strictInterviewing.SaveSessionOnQuit)
+	session.Set(DELETE_SESSION_ON_TIMEOUT_KEY, strictInterviewing.DeleteSessionOnTimeout)
+	session.Set(DELETE_SESSION_ON_QUIT_KEY, strictInterviewing.DeleteSessionOnQuit)
 	if err := session.Save(); err != nil {
 		auth.Logger.Error("Failed to save JWT to session", zap.Error(err))
 		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
-		return
+		return false
 	}
 
 	validationSession := sessions.DefaultMany(context, "session_validation")
@@ -174,14 +424,32 @@ func (auth *Auth) Login(context *gin.Context, session sessions.Session) {
 	if err := validationSession.Save(); err != nil {
 		auth.Logger.Error("Failed to save validationSession", zap.Error(err))
 		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
-		return
+		return false
 	}
 
 	context.Redirect(http.StatusFound, fmt.Sprintf("/%s/", uacInfo.InstrumentName))
 	context.Abort()
+	return true
+}
+
+func (auth *Auth) throttled(context *gin.Context) bool {
+	retryAfter := auth.Throttle.Throttled(context)
+	if retryAfter <= 0 {
+		return false
+	}
+	auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+		zap.String("Reason", "Too many attempts"), zap.Duration("RetryAfter", retryAfter))...)
+	auth.NotAuthWithError(context, fmt.Sprintf(
+		auth.LanguageManager.LanguageError(TOO_MANY_ATTEMPTS_ERR, context),
+		throttle.RetryAfterMinutes(retryAfter),
+	))
+	return true
 }
 
 func (auth *Auth) Logout(context *gin.Context, session sessions.Session) {
+	saved, lost := QuitAnswers(session)
+	auth.revokeSession(session)
+
 	session.Set(JWT_TOKEN_KEY, "")
 	session.Clear()
 	session.Options(sessions.Options{MaxAge: -1})
@@ -190,12 +458,39 @@ func (auth *Auth) Logout(context *gin.Context, session sessions.Session) {
 		auth.notAuth(context)
 		return
 	}
-	context.HTML(http.StatusOK, "logout.tmpl", gin.H{"welsh": auth.LanguageManager.IsWelsh(context)})
+	context.HTML(http.StatusOK, "logout.tmpl", gin.H{
+		"welsh": auth.LanguageManager.IsWelsh(context),
+		"saved": saved,
+		"lost":  lost,
+	})
+}
+
+// TimeoutAnswers reports whether Blaise saved the respondent's answers when
+// their session timed out, or deleted them so they will need to start again,
+// going by the instrument's StrictInterviewing settings at login
+func TimeoutAnswers(session sessions.Session) (saved, lost bool) {
+	return answers(session, SAVE_SESSION_ON_TIMEOUT_KEY, DELETE_SESSION_ON_TIMEOUT_KEY)
+}
+
+// QuitAnswers is TimeoutAnswers for a respondent choosing to log out
+func QuitAnswers(session sessions.Session) (saved, lost bool) {
+	return answers(session, SAVE_SESSION_ON_QUIT_KEY, DELETE_SESSION_ON_QUIT_KEY)
+}
+
+func answers(session sessions.Session, saveKey, deleteKey string) (saved, lost bool) {
+	saved = sessionFlag(session, saveKey)
+	return saved, !saved && sessionFlag(session, deleteKey)
+}
+
+func sessionFlag(session sessions.Session, key string) bool {
+	flag, ok := session.Get(key).(bool)
+	return ok && flag
 }
 
 func (auth *Auth) notAuth(context *gin.Context) {
 	context.HTML(http.StatusUnauthorized, "login.tmpl", gin.H{
-		"uac16":      auth.isUac16(),
+		"uac12":      auth.UacKind == UAC12,
+		"uac16":      auth.UacKind == UAC16,
 		"csrf_token": auth.CSRFManager.GetToken(context),
 		"welsh":      auth.LanguageManager.IsWelsh(context),
 	})
@@ -205,7 +500,8 @@ func (auth *Auth) notAuth(context *gin.Context) {
 func (auth *Auth) NotAuthWithError(context *gin.Context, errorMessage string) {
 	context.HTML(http.StatusUnauthorized, "login.tmpl", gin.H{
 		"error":      errorMessage,
-		"uac16":      auth.isUac16(),
+		"uac12":      auth.UacKind == UAC12,
+		"uac16":      auth.UacKind == UAC16,
 		"csrf_token": auth.CSRFManager.GetToken(context),
 		"welsh":      auth.LanguageManager.IsWelsh(context),
 	})
@@ -217,6 +513,92 @@ func (auth *Auth) InstrumentNotInstalledError(context *gin.Context) {
 	context.Abort()
 }
 
+// uacLookupFailed tells the user why their UAC could not be checked when the
+// failure was not the UAC's fault, so it is not counted as a failed attempt
+func (auth *Auth) uacLookupFailed(context *gin.Context, err error) {
+	var (
+		unauthorizedError *upstream.UnauthorizedError
+		unavailableError  *upstream.UnavailableError
+		malformedError    *upstream.MalformedResponseError
+	)
+	switch {
+	case context.Request.Context().Err() != nil:
+		auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Request cancelled"),
+			zap.Error(err),
+		)...)
+		context.AbortWithStatus(http.StatusRequestTimeout)
+	case errors.Is(err, circuitbreaker.OpenError):
+		auth.Logger.Warn("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "BUS unavailable, failing fast"),
+			zap.Error(err),
+		)...)
+		auth.ServiceBusy(context)
+	case errors.As(err, &unavailableError):
+		auth.Logger.Error("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "BUS unavailable"),
+			zap.Int("BusStatusCode", unavailableError.StatusCode),
+			zap.String("BusResponseBody", unavailableError.Body),
+			zap.Error(err),
+		)...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(UAC_CHECK_UNAVAILABLE_ERR, context))
+	case errors.As(err, &unauthorizedError):
+		auth.Logger.Error("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Not authorised to call BUS"),
+			zap.Int("BusStatusCode", unauthorizedError.StatusCode),
+			zap.String("BusResponseBody", unauthorizedError.Body),
+			zap.Error(err),
+		)...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(SERVICE_PROBLEM_ERR, context))
+	case errors.As(err, &malformedError):
+		auth.Logger.Error("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Malformed response from BUS"),
+			zap.Int("BusStatusCode", malformedError.StatusCode),
+			zap.String("BusResponseBody", malformedError.Body),
+			zap.Error(err),
+		)...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
+	default:
+		auth.Logger.Error("Failed auth", append(utils.GetRequestSource(context),
+			zap.String("Reason", "Could not look up access code"),
+			zap.Error(err),
+		)...)
+		auth.NotAuthWithError(context, auth.LanguageManager.LanguageError(INTERNAL_SERVER_ERR, context))
+	}
+}
+
+func isUacNotFound(err error) bool {
+	var notFoundError *upstream.NotFoundError
+	return errors.As(err, &notFoundError)
+}
+
+func (auth *Auth) ServiceBusy(context *gin.Context) {
+	context.HTML(http.StatusServiceUnavailable, "service_busy.tmpl", gin.H{"welsh": auth.LanguageManager.IsWelsh(context)})
+	context.Abort()
+}
+
+func (auth *Auth) CaseAlreadyCompleted(context *gin.Context) {
+	context.HTML(http.StatusOK, "already_completed.tmpl", gin.H{"welsh": auth.LanguageManager.IsWelsh(context)})
+	context.Abort()
+}
+
+func (auth *Auth) inFieldPeriod(context *gin.Context, uacInfo busapi.UacInfo) bool {
+	if auth.FieldPeriods == nil {
+		return true
+	}
+	status, fieldPeriod := auth.FieldPeriods.Check(uacInfo.InstrumentName, time.Now())
+	if status == fieldperiod.OPEN {
+		return true
+	}
+	auth.Logger.Info("Failed auth", append(utils.GetRequestSource(context),
+		zap.String("Reason", FieldPeriodReason(status)),
+		zap.String("InstrumentName", uacInfo.InstrumentName),
+		zap.String("CaseID", uacInfo.CaseID),
+	)...)
+	OutsideFieldPeriod(context, auth.LanguageManager.IsWelsh(context), status, fieldPeriod)
+	return false
+}
+
 func (auth *Auth) RefreshToken(context *gin.Context, session sessions.Session, claim *UACClaims) {
 	jwtToken := session.Get(JWT_TOKEN_KEY)
 	if jwtToken == nil || jwtToken.(string) == "" ||
@@ -229,7 +611,7 @@ func (auth *Auth) RefreshToken(context *gin.Context, session sessions.Session, c
 		return
 	}
 
-	signedToken, err := auth.JWTCrypto.EncryptJWT(claim.UAC, &claim.UacInfo, claim.AuthTimeout)
+	signedToken, err := auth.JWTCrypto.RefreshJWT(claim)
 	if err != nil {
 		auth.Logger.Error("Failed to Encrypt JWT", zap.Error(err))
 		return
@@ -240,9 +622,42 @@ func (auth *Auth) RefreshToken(context *gin.Context, session sessions.Session, c
 		auth.Logger.Error("Failed to save JWT to session", zap.Error(err))
 		return
 	}
+
+	if !claim.Registered() {
+		return
+	}
+	if err := auth.Sessions.Refresh(claim.UacRef, claim.Id, sessionLifetime(claim.AuthTimeout)); err != nil {
+		auth.Logger.Error("Failed to refresh session registration", append(claim.LogFields(), zap.Error(err))...)
+	}
 	return
 }
 
+// sessionActive reports whether the claim is for the UAC's active session.
+// Sessions started before they were registered are taken to be active until
+// they expire, rather than logging everyone out.
+func (auth *Auth) sessionActive(claim *UACClaims) (bool, error) {
+	if !claim.Registered() {
+		return true, nil
+	}
+	return auth.Sessions.Active(claim.UacRef, claim.Id)
+}
+
+// revokeSession ends the UAC's sessions everywhere, provided the session
+// logging out is the UAC's active one
+func (auth *Auth) revokeSession(session sessions.Session) {
+	claim, err := auth.JWTCrypto.DecryptJWT(session.Get(JWT_TOKEN_KEY))
+	if err != nil || !claim.Registered() {
+		return
+	}
+	active, err := auth.Sessions.Active(claim.UacRef, claim.Id)
+	if err == nil && active {
+		err = auth.Sessions.Revoke(claim.UacRef)
+	}
+	if err != nil {
+		auth.Logger.Error("Failed to revoke session", append(claim.LogFields(), zap.Error(err))...)
+	}
+}
+
 func (auth *Auth) SessionValid(context *gin.Context) bool {
 	validationSession := sessions.DefaultMany(context, "session_validation")
 	sessionValid := validationSession.Get(SESSION_VALID_KEY)
@@ -260,21 +675,99 @@ func (auth *Auth) clearSessionValidation(context *gin.Context) error {
 	return validationSession.Save()
 }
 
-func (auth *Auth) isUac16() bool {
-	return auth.UacKind == "uac16"
+// acceptedUacKinds returns the kinds of UAC this deployment accepts, which is
+// both unless UacKind restricts it to one
+func (auth *Auth) acceptedUacKinds() []string {
+	switch auth.UacKind {
+	case UAC12, UAC16:
+		return []string{auth.UacKind}
+	}
+	return []string{UAC12, UAC16}
+}
+
+// detectUacKind works out which accepted kind of UAC was entered from its
+// normalised length, returning the kind and the normalised UAC. The kind is
+// empty if the length matches none of the accepted kinds.
+func (auth *Auth) detectUacKind(enteredUac string) (string, string) {
+	var uac string
+	for _, uacKind := range auth.acceptedUacKinds() {
+		uac = auth.UacValidators[uacKind].Normalise(enteredUac)
+		if len(uac) == UAC_LENGTHS[uacKind] {
+			return uacKind, uac
+		}
+	}
+	return "", uac
+}
+
+// sessionMaxLifetime is the instrument's own maximum session lifetime, if it
+// has one, or the global one
+func (auth *Auth) sessionMaxLifetime(instrumentName string) time.Duration {
+	patterns := make([]string, 0, len(auth.InstrumentSessionMaxLifetimes))
+	for pattern := range auth.InstrumentSessionMaxLifetimes {
+		patterns = append(patterns, pattern)
+	}
+	if pattern, found := utils.MatchInstrument(instrumentName, patterns); found {
+		return auth.InstrumentSessionMaxLifetimes[pattern]
+	}
+	return auth.SessionMaxLifetime
+}
+
+func sessionLifetime(authTimeout int) time.Duration {
+	if authTimeout == 0 {
+		authTimeout = DefaultAuthTimeout
+	}
+	return time.Duration(authTimeout) * time.Minute
+}
+
+func uacValidationReason(err error) string {
+	switch err {
+	case InvalidUacCharactersError:
+		return "Invalid UAC characters"
+	case InvalidUacCheckDigitError:
+		return "Invalid UAC check digit"
+	}
+	return "Invalid UAC"
+}
+
+func uacValidationError(err error) map[string]string {
+	switch err {
+	case InvalidUacCharactersError:
+		return INVALID_CHARACTERS_ERR
+	case InvalidUacCheckDigitError:
+		return INVALID_CHECK_DIGIT_ERR
+	}
+	return NOT_RECOGNISED_ERR
 }
 
 func (auth *Auth) uacError(context *gin.Context) string {
+	uacKind := UAC_BOTH
+	if acceptedUacKinds := auth.acceptedUacKinds(); len(acceptedUacKinds) == 1 {
+		uacKind = acceptedUacKinds[0]
+	}
 	if auth.LanguageManager.IsWelsh(context) {
-		if auth.isUac16() {
-			return fmt.Sprintf(INVALID_LENGTH_ERR["welsh"], "16 o nodau")
-		}
-		return fmt.Sprintf(INVALID_LENGTH_ERR["welsh"], "12 o nodau")
+		return fmt.Sprintf(INVALID_LENGTH_ERR["welsh"], UAC_DESCRIPTIONS[uacKind]["welsh"])
 	}
-	if auth.isUac16() {
-		return fmt.Sprintf(INVALID_LENGTH_ERR["english"], "16-character")
+	return fmt.Sprintf(INVALID_LENGTH_ERR["english"], UAC_DESCRIPTIONS[uacKind]["english"])
+}
+
+// OutsideFieldPeriod shows when an instrument opens, or that it has closed
+func OutsideFieldPeriod(context *gin.Context, welsh bool, status fieldperiod.Status, fieldPeriod fieldperiod.FieldPeriod) {
+	if status == fieldperiod.NOT_OPEN {
+		context.HTML(http.StatusForbidden, "not_open.tmpl", gin.H{
+			"opens": fieldperiod.FormatDate(fieldPeriod.Opens, welsh),
+			"welsh": welsh,
+		})
+	} else {
+		context.HTML(http.StatusForbidden, "closed.tmpl", gin.H{"welsh": welsh})
+	}
+	context.Abort()
+}
+
+func FieldPeriodReason(status fieldperiod.Status) string {
+	if status == fieldperiod.NOT_OPEN {
+		return "Study not open yet"
 	}
-	return fmt.Sprintf(INVALID_LENGTH_ERR["english"], "12-digit")
+	return "Study closed"
 }
 
 func Forbidden(context *gin.Context, welsh bool) {
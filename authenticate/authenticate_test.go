@@ -1,12 +1,14 @@
 package authenticate_test
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
 	mockauth "github.com/ONSdigital/blaise-cawi-portal/authenticate/mocks"
@@ -14,11 +16,20 @@ import (
 	mockrestapi "github.com/ONSdigital/blaise-cawi-portal/blaiserestapi/mocks"
 	"github.com/ONSdigital/blaise-cawi-portal/busapi"
 	"github.com/ONSdigital/blaise-cawi-portal/busapi/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/circuitbreaker"
+	"github.com/ONSdigital/blaise-cawi-portal/fieldperiod"
+	fieldPeriodMocks "github.com/ONSdigital/blaise-cawi-portal/fieldperiod/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
 	languageManagerMocks "github.com/ONSdigital/blaise-cawi-portal/languagemanager/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/sessionregistry"
+	registryMocks "github.com/ONSdigital/blaise-cawi-portal/sessionregistry/mocks"
+	throttleMocks "github.com/ONSdigital/blaise-cawi-portal/throttle/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
 	"github.com/ONSdigital/blaise-cawi-portal/webserver"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
 	csrf "github.com/srbry/gin-csrf"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
@@ -26,21 +37,24 @@ import (
 	"go.uber.org/zap/zaptest/observer"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("Login", func() {
 	var (
-		shortUAC    = "22222"
-		longUAC     = "11112222333344445555"
-		spacedUAC   = "1234 5678 9012"
-		spacedUAC16 = "bcdf 5678 ghjk 2345"
-		validUAC    = "123456789012"
-		validUAC16  = "bcdf5678ghjk2345"
-		jwtCrypto   = &authenticate.JWTCrypto{
+		shortUAC        = "22222"
+		longUAC         = "11112222333344445555"
+		spacedUAC       = "1234 5678 9012"
+		spacedUAC16     = "bcdf 5678 ghjk 2345"
+		validUAC        = "123456789012"
+		validUAC16      = "bcdf5678ghjk2345"
+		normalisedUAC16 = "BCDF5678GHJK2345"
+		jwtCrypto       = &authenticate.JWTCrypto{
 			JWTSecret: "hello",
 		}
 		languageManagerMock *languageManagerMocks.LanguageManagerInterface
+		throttleMock        *throttleMocks.ThrottleInterface
 		auth                *authenticate.Auth
 		httpRouter          *gin.Engine
 		httpRecorder        *httptest.ResponseRecorder
@@ -58,13 +72,19 @@ var _ = Describe("Login", func() {
 		observedLogger := zap.New(observedZapCore)
 		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
 		languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+		languageManagerMock.On("LanguageError", authenticate.TOO_MANY_ATTEMPTS_ERR, mock.Anything).Return("Too many attempts to enter an access code. Try again in %d minutes")
 		languageManagerMock.On("LanguageError", mock.Anything, mock.Anything).Return("Access code not recognised. Enter the code again")
+		throttleMock = &throttleMocks.ThrottleInterface{}
+		throttleMock.On("RecordFailure", mock.Anything).Return()
 		auth = &authenticate.Auth{
 			JWTCrypto:       jwtCrypto,
 			Logger:          observedLogger,
 			CSRFManager:     csrfManager,
 			LanguageManager: languageManagerMock,
+			Throttle:        throttleMock,
 		}
+		auth.UacValidators, _ = authenticate.NewUacValidators(authenticate.CHECKSUM_NONE, authenticate.CHECKSUM_NONE, "")
+		auth.Sessions, _ = sessionregistry.NewRegistry(kvstore.NewMemoryStore(), sessionregistry.POLICY_EVICT)
 		httpRouter = gin.Default()
 		httpRouter.SetFuncMap(template.FuncMap{
 			"WrapWelsh": webserver.WrapWelsh,
@@ -78,6 +98,147 @@ var _ = Describe("Login", func() {
 		})
 	})
 
+	Context("When too many failed attempts have been made", func() {
+		var mockBusApi *mocks.BusApiInterface
+
+		BeforeEach(func() {
+			auth.UacKind = "uac"
+			mockBusApi = &mocks.BusApiInterface{}
+			auth.BusApi = mockBusApi
+			throttleMock.On("Throttled", mock.Anything).Return(4*time.Minute + 10*time.Second)
+		})
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			data := url.Values{
+				"uac": []string{validUAC},
+			}
+			req, _ := http.NewRequest("POST", "/login", strings.NewReader(data.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			req.RemoteAddr = "1.1.1.1"
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		It("returns a status unauthorised with a try again later error", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(`Too many attempts to enter an access code. Try again in 5 minutes`))
+		})
+
+		It("does not look up the UAC", func() {
+			mockBusApi.AssertNotCalled(GinkgoT(), "GetUacInfo", mock.Anything, mock.Anything)
+		})
+
+		It("logs the throttled attempt", func() {
+			Expect(observedLogs.Len()).To(Equal(1))
+			Expect(observedLogs.All()[0].Message).To(Equal("Failed auth"))
+			Expect(observedLogs.All()[0].ContextMap()["SourceIP"]).To(Equal("1.1.1.1"))
+			Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Too many attempts"))
+			Expect(observedLogs.All()[0].Level).To(Equal(zap.InfoLevel))
+		})
+	})
+
+	Context("When BUS is failing fast", func() {
+		BeforeEach(func() {
+			auth.UacKind = "uac"
+			throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
+			mockBusApi := &mocks.BusApiInterface{}
+			auth.BusApi = mockBusApi
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Return(busapi.UacInfo{}, circuitbreaker.OpenError)
+
+			httpRecorder = httptest.NewRecorder()
+			data := url.Values{
+				"uac": []string{validUAC},
+			}
+			req, _ := http.NewRequest("POST", "/login", strings.NewReader(data.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		It("returns the service busy page", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring("Please try again in a few minutes."))
+			Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+		})
+
+		It("does not count it as a failed attempt", func() {
+			throttleMock.AssertNotCalled(GinkgoT(), "RecordFailure", mock.Anything)
+			Expect(observedLogs.Len()).To(Equal(1))
+			Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("BUS unavailable, failing fast"))
+			Expect(observedLogs.All()[0].Level).To(Equal(zap.WarnLevel))
+		})
+	})
+
+	DescribeTable("When BUS cannot look up the UAC",
+		func(busErr error, expectedError map[string]string, expectedReason string, expectedLevel zapcore.Level, recordsFailure bool) {
+			auth.UacKind = "uac"
+			throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
+			mockBusApi := &mocks.BusApiInterface{}
+			auth.BusApi = mockBusApi
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Return(busapi.UacInfo{}, busErr)
+
+			httpRecorder = httptest.NewRecorder()
+			data := url.Values{
+				"uac": []string{validUAC},
+			}
+			req, _ := http.NewRequest("POST", "/login", strings.NewReader(data.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			httpRouter.ServeHTTP(httpRecorder, req)
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			languageManagerMock.AssertCalled(GinkgoT(), "LanguageError", expectedError, mock.Anything)
+			Expect(observedLogs.Len()).To(Equal(1))
+			Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal(expectedReason))
+			Expect(observedLogs.All()[0].Level).To(Equal(expectedLevel))
+			if recordsFailure {
+				throttleMock.AssertCalled(GinkgoT(), "RecordFailure", mock.Anything)
+			} else {
+				throttleMock.AssertNotCalled(GinkgoT(), "RecordFailure", mock.Anything)
+				Expect(observedLogs.All()[0].ContextMap()["BusStatusCode"]).ToNot(BeNil())
+			}
+		},
+		Entry("because it is not known",
+			&upstream.NotFoundError{Upstream: busapi.UPSTREAM, Response: upstream.Response{StatusCode: 404}},
+			authenticate.NOT_RECOGNISED_ERR, "Access code not recognised", zap.InfoLevel, true),
+		Entry("because BUS is unavailable",
+			&upstream.UnavailableError{Upstream: busapi.UPSTREAM, Response: upstream.Response{StatusCode: 503, Body: "down"}},
+			authenticate.UAC_CHECK_UNAVAILABLE_ERR, "BUS unavailable", zap.ErrorLevel, false),
+		Entry("because the portal is not authorised",
+			&upstream.UnauthorizedError{Upstream: busapi.UPSTREAM, Response: upstream.Response{StatusCode: 401}},
+			authenticate.SERVICE_PROBLEM_ERR, "Not authorised to call BUS", zap.ErrorLevel, false),
+		Entry("because BUS sent a malformed response",
+			&upstream.MalformedResponseError{Upstream: busapi.UPSTREAM, Response: upstream.Response{StatusCode: 200, Body: "<html>"}},
+			authenticate.INTERNAL_SERVER_ERR, "Malformed response from BUS", zap.ErrorLevel, false),
+	)
+
+	Context("When the respondent gives up before BUS responds", func() {
+		BeforeEach(func() {
+			auth.UacKind = "uac"
+			throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
+			mockBusApi := &mocks.BusApiInterface{}
+			auth.BusApi = mockBusApi
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Return(busapi.UacInfo{}, context.Canceled)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			httpRecorder = httptest.NewRecorder()
+			data := url.Values{
+				"uac": []string{validUAC},
+			}
+			req, _ := http.NewRequestWithContext(ctx, "POST", "/login", strings.NewReader(data.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		It("stops without counting a failed attempt", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusRequestTimeout))
+			throttleMock.AssertNotCalled(GinkgoT(), "RecordFailure", mock.Anything)
+			Expect(observedLogs.Len()).To(Equal(1))
+			Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Request cancelled"))
+			Expect(observedLogs.All()[0].Level).To(Equal(zap.InfoLevel))
+		})
+	})
+
 	Context("When an instrument is not installed", func() {
 		var uacValue string
 
@@ -94,14 +255,15 @@ var _ = Describe("Login", func() {
 		BeforeEach(func() {
 			uacValue = validUAC
 			auth.UacKind = "uac"
+			throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
 			mockBusApi := &mocks.BusApiInterface{}
 			auth.BusApi = mockBusApi
 
-			mockBusApi.On("GetUacInfo", validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
 
 			mockRestApi := &mockrestapi.BlaiseRestApiInterface{}
 			auth.BlaiseRestApi = mockRestApi
-			mockRestApi.On("GetInstrumentSettings", mock.Anything).Return(blaiserestapi.InstrumentSettings{}, blaiserestapi.InstrumentNotFoundError)
+			mockRestApi.On("GetInstrumentSettings", mock.Anything, mock.Anything).Return(blaiserestapi.InstrumentSettings{}, blaiserestapi.InstrumentNotFoundError)
 		})
 
 		It("returns the not live page", func() {
@@ -123,11 +285,164 @@ var _ = Describe("Login", func() {
 		})
 	})
 
+	Context("When checking the case status", func() {
+		var (
+			caseStatus    blaiserestapi.CaseStatus
+			caseStatusErr error
+		)
+
+		JustBeforeEach(func() {
+			mockRestApi := &mockrestapi.BlaiseRestApiInterface{}
+			auth.BlaiseRestApi = mockRestApi
+			mockRestApi.On("GetInstrumentSettings", mock.Anything, "foo").Return(blaiserestapi.InstrumentSettings{}, nil)
+			mockRestApi.On("GetCaseStatus", mock.Anything, "foo", "bar").Return(caseStatus, caseStatusErr)
+
+			httpRecorder = httptest.NewRecorder()
+			data := url.Values{
+				"uac": []string{validUAC},
+			}
+			req, _ := http.NewRequest("POST", "/login", strings.NewReader(data.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			auth.UacKind = "uac"
+			caseStatusErr = nil
+			throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
+			mockBusApi := &mocks.BusApiInterface{}
+			auth.BusApi = mockBusApi
+
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+		})
+
+		Context("and the case has already been completed", func() {
+			BeforeEach(func() {
+				caseStatus = blaiserestapi.CaseStatus{PrimaryKey: "bar", Outcome: blaiserestapi.OUTCOME_COMPLETED}
+			})
+
+			It("returns the already completed page without logging in", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(`You have already completed this study`))
+				Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+			})
+
+			It("logs the completed case", func() {
+				Expect(observedLogs.Len()).To(Equal(1))
+				Expect(observedLogs.All()[0].Message).To(Equal("Failed auth"))
+				Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Case already completed"))
+				Expect(observedLogs.All()[0].ContextMap()["InstrumentName"]).To(Equal("foo"))
+				Expect(observedLogs.All()[0].ContextMap()["CaseID"]).To(Equal("bar"))
+				Expect(observedLogs.All()[0].Level).To(Equal(zap.InfoLevel))
+			})
+		})
+
+		Context("and the case is partially complete", func() {
+			BeforeEach(func() {
+				caseStatus = blaiserestapi.CaseStatus{PrimaryKey: "bar", Outcome: blaiserestapi.OUTCOME_PARTIAL}
+			})
+
+			It("redirects to /:instrumentName/", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+				Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
+				Expect(session.Get(authenticate.JWT_TOKEN_KEY)).ToNot(BeNil())
+			})
+		})
+
+		Context("and the case status cannot be found", func() {
+			BeforeEach(func() {
+				caseStatus = blaiserestapi.CaseStatus{}
+				caseStatusErr = blaiserestapi.CaseNotFoundError
+			})
+
+			It("logs a warning and redirects to /:instrumentName/", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+				Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
+				Expect(observedLogs.All()[0].Message).To(Equal("Could not get case status, continuing with login"))
+				Expect(observedLogs.All()[0].Level).To(Equal(zap.WarnLevel))
+			})
+		})
+	})
+
+	Context("When the instrument is outside of its field period", func() {
+		var (
+			mockRestApi      *mockrestapi.BlaiseRestApiInterface
+			mockFieldPeriods *fieldPeriodMocks.FieldPeriodsInterface
+			status           fieldperiod.Status
+		)
+
+		JustBeforeEach(func() {
+			mockFieldPeriods.On("Check", "foo", mock.Anything).Return(status, fieldperiod.FieldPeriod{
+				Opens: time.Date(2021, 6, 1, 0, 0, 0, 0, fieldperiod.Location),
+			})
+
+			httpRecorder = httptest.NewRecorder()
+			data := url.Values{
+				"uac": []string{validUAC},
+			}
+			req, _ := http.NewRequest("POST", "/login", strings.NewReader(data.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		BeforeEach(func() {
+			auth.UacKind = "uac"
+			throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
+			mockBusApi := &mocks.BusApiInterface{}
+			auth.BusApi = mockBusApi
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+			mockRestApi = &mockrestapi.BlaiseRestApiInterface{}
+			auth.BlaiseRestApi = mockRestApi
+			mockFieldPeriods = &fieldPeriodMocks.FieldPeriodsInterface{}
+			auth.FieldPeriods = mockFieldPeriods
+		})
+
+		AfterEach(func() {
+			auth.FieldPeriods = nil
+		})
+
+		Context("because it has not opened", func() {
+			BeforeEach(func() {
+				status = fieldperiod.NOT_OPEN
+			})
+
+			It("shows when the study opens without logging in", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring("The study opens on 1 June 2021"))
+				Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+				mockRestApi.AssertNotCalled(GinkgoT(), "GetInstrumentSettings", mock.Anything, mock.Anything)
+
+				Expect(observedLogs.Len()).To(Equal(1))
+				Expect(observedLogs.All()[0].Message).To(Equal("Failed auth"))
+				Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Study not open yet"))
+				Expect(observedLogs.All()[0].ContextMap()["InstrumentName"]).To(Equal("foo"))
+				Expect(observedLogs.All()[0].ContextMap()["CaseID"]).To(Equal("bar"))
+			})
+		})
+
+		Context("because it has closed", func() {
+			BeforeEach(func() {
+				status = fieldperiod.CLOSED
+			})
+
+			It("shows that the study has closed without logging in", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring("This study has closed"))
+				Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+
+				Expect(observedLogs.Len()).To(Equal(1))
+				Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Study closed"))
+			})
+		})
+	})
+
 	Context("When instrument settings does not error", func() {
 		BeforeEach(func() {
+			throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
 			mockRestApi := &mockrestapi.BlaiseRestApiInterface{}
 			auth.BlaiseRestApi = mockRestApi
-			mockRestApi.On("GetInstrumentSettings", mock.Anything).Return(blaiserestapi.InstrumentSettings{}, nil)
+			mockRestApi.On("GetInstrumentSettings", mock.Anything, mock.Anything).Return(blaiserestapi.InstrumentSettings{}, nil)
+			mockRestApi.On("GetCaseStatus", mock.Anything, mock.Anything, mock.Anything).Return(blaiserestapi.CaseStatus{}, nil)
 		})
 
 		Context("Login with a correct length, invalid UAC Code", func() {
@@ -150,7 +465,7 @@ var _ = Describe("Login", func() {
 				mockBusApi := &mocks.BusApiInterface{}
 				auth.BusApi = mockBusApi
 
-				mockBusApi.On("GetUacInfo", validUAC).Once().Return(busapi.UacInfo{InstrumentName: "", CaseID: "bar"}, nil)
+				mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "", CaseID: "bar"}, nil)
 			})
 
 			It("returns a status unauthorised with an error", func() {
@@ -169,6 +484,83 @@ var _ = Describe("Login", func() {
 				Expect(observedLogs.All()[0].ContextMap()["error"]).To(BeNil())
 				Expect(observedLogs.All()[0].Level).To(Equal(zap.InfoLevel))
 			})
+
+			It("records a failed attempt", func() {
+				throttleMock.AssertNumberOfCalls(GinkgoT(), "RecordFailure", 1)
+			})
+		})
+
+		Context("Login with a correct length UAC Code that fails validation", func() {
+			var (
+				mockBusApi          *mocks.BusApiInterface
+				mockUacValidator    *mockauth.UacValidatorInterface
+				validationError     error
+				localisedValidation string
+			)
+
+			JustBeforeEach(func() {
+				languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
+				languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+				languageManagerMock.On("LanguageError", mock.Anything, mock.Anything).Return(localisedValidation)
+				auth.LanguageManager = languageManagerMock
+				mockUacValidator.On("Normalise", validUAC).Return(validUAC)
+				mockUacValidator.On("Validate", validUAC).Return(validationError)
+
+				httpRecorder = httptest.NewRecorder()
+				data := url.Values{
+					"uac": []string{validUAC},
+				}
+				req, _ := http.NewRequest("POST", "/login", strings.NewReader(data.Encode()))
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.RemoteAddr = "1.1.1.1"
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			BeforeEach(func() {
+				auth.UacKind = "uac"
+				mockBusApi = &mocks.BusApiInterface{}
+				auth.BusApi = mockBusApi
+				mockUacValidator = &mockauth.UacValidatorInterface{}
+				auth.UacValidators = map[string]authenticate.UacValidatorInterface{
+					authenticate.UAC12: mockUacValidator,
+				}
+			})
+
+			Context("because of invalid characters", func() {
+				BeforeEach(func() {
+					validationError = authenticate.InvalidUacCharactersError
+					localisedValidation = authenticate.INVALID_CHARACTERS_ERR["english"]
+				})
+
+				It("returns a status unauthorised with a character set error", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+					Expect(httpRecorder.Body.String()).To(ContainSubstring(`Enter your access code using only the letters and numbers shown on your letter`))
+					languageManagerMock.AssertCalled(GinkgoT(), "LanguageError", authenticate.INVALID_CHARACTERS_ERR, mock.Anything)
+					mockBusApi.AssertNotCalled(GinkgoT(), "GetUacInfo", mock.Anything, mock.Anything)
+
+					Expect(observedLogs.Len()).To(Equal(1))
+					Expect(observedLogs.All()[0].Message).To(Equal("Failed auth"))
+					Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Invalid UAC characters"))
+				})
+			})
+
+			Context("because of an invalid check digit", func() {
+				BeforeEach(func() {
+					validationError = authenticate.InvalidUacCheckDigitError
+					localisedValidation = authenticate.INVALID_CHECK_DIGIT_ERR["english"]
+				})
+
+				It("returns a status unauthorised with a check digit error", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+					Expect(httpRecorder.Body.String()).To(ContainSubstring(`Check you have entered the code exactly as it appears on your letter`))
+					languageManagerMock.AssertCalled(GinkgoT(), "LanguageError", authenticate.INVALID_CHECK_DIGIT_ERR, mock.Anything)
+					mockBusApi.AssertNotCalled(GinkgoT(), "GetUacInfo", mock.Anything, mock.Anything)
+					throttleMock.AssertNotCalled(GinkgoT(), "RecordFailure", mock.Anything)
+
+					Expect(observedLogs.Len()).To(Equal(1))
+					Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Invalid UAC check digit"))
+				})
+			})
 		})
 
 		Context("Login with a valid UAC Code", func() {
@@ -191,7 +583,7 @@ var _ = Describe("Login", func() {
 					mockBusApi := &mocks.BusApiInterface{}
 					auth.BusApi = mockBusApi
 
-					mockBusApi.On("GetUacInfo", validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
 				})
 
 				It("redirects to /:instrumentName/", func() {
@@ -199,7 +591,7 @@ var _ = Describe("Login", func() {
 					Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
 					Expect(httpRecorder.Result().Cookies()).ToNot(BeEmpty())
 					decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
-					Expect(decryptedToken.UAC).To(Equal(validUAC))
+					Expect(decryptedToken.UacRef).To(Equal(jwtCrypto.UacRef(validUAC)))
 					Expect(decryptedToken.UacInfo.InstrumentName).To(Equal("foo"))
 					Expect(decryptedToken.UacInfo.CaseID).To(Equal("bar"))
 					Expect(session.Get(authenticate.SESSION_TIMEOUT_KEY).(int)).To(Equal(15))
@@ -213,7 +605,7 @@ var _ = Describe("Login", func() {
 					mockBusApi := &mocks.BusApiInterface{}
 					auth.BusApi = mockBusApi
 
-					mockBusApi.On("GetUacInfo", validUAC16).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					mockBusApi.On("GetUacInfo", mock.Anything, normalisedUAC16).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
 				})
 
 				It("redirects to /:instrumentName/", func() {
@@ -221,13 +613,145 @@ var _ = Describe("Login", func() {
 					Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
 					Expect(httpRecorder.Result().Cookies()).ToNot(BeEmpty())
 					decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
-					Expect(decryptedToken.UAC).To(Equal(validUAC16))
+					Expect(decryptedToken.UacRef).To(Equal(jwtCrypto.UacRef(normalisedUAC16)))
 					Expect(decryptedToken.UacInfo.InstrumentName).To(Equal("foo"))
 					Expect(decryptedToken.UacInfo.CaseID).To(Equal("bar"))
 					Expect(session.Get(authenticate.SESSION_TIMEOUT_KEY).(int)).To(Equal(15))
 				})
 			})
 
+			Context("Login with both UAC kinds accepted", func() {
+				var mockBusApi *mocks.BusApiInterface
+
+				BeforeEach(func() {
+					auth.UacKind = "both"
+					mockBusApi = &mocks.BusApiInterface{}
+					auth.BusApi = mockBusApi
+				})
+
+				Context("and a 12 digit UAC is entered", func() {
+					BeforeEach(func() {
+						uacValue = validUAC
+						mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					})
+
+					It("redirects to /:instrumentName/", func() {
+						Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+						Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
+						decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
+						Expect(decryptedToken.UacRef).To(Equal(jwtCrypto.UacRef(validUAC)))
+						mockBusApi.AssertExpectations(GinkgoT())
+					})
+				})
+
+				Context("and a 16 character UAC is entered", func() {
+					BeforeEach(func() {
+						uacValue = validUAC16
+						mockBusApi.On("GetUacInfo", mock.Anything, normalisedUAC16).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					})
+
+					It("redirects to /:instrumentName/", func() {
+						Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+						Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
+						decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
+						Expect(decryptedToken.UacRef).To(Equal(jwtCrypto.UacRef(normalisedUAC16)))
+						mockBusApi.AssertExpectations(GinkgoT())
+					})
+				})
+			})
+
+			Context("Login to an instrument with StrictInterviewing settings", func() {
+				BeforeEach(func() {
+					uacValue = validUAC
+					auth.UacKind = "uac"
+					mockBusApi := &mocks.BusApiInterface{}
+					auth.BusApi = mockBusApi
+					mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					mockRestApi := &mockrestapi.BlaiseRestApiInterface{}
+					auth.BlaiseRestApi = mockRestApi
+					mockRestApi.On("GetInstrumentSettings", mock.Anything, "foo").Return(blaiserestapi.InstrumentSettings{
+						{
+							Type:                   "StrictInterviewing",
+							SessionTimeout:         30,
+							SaveSessionOnTimeout:   true,
+							DeleteSessionOnTimeout: true,
+							DeleteSessionOnQuit:    true,
+						},
+					}, nil)
+					mockRestApi.On("GetCaseStatus", mock.Anything, mock.Anything, mock.Anything).Return(blaiserestapi.CaseStatus{}, nil)
+				})
+
+				It("carries the settings in the session", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+					Expect(session.Get(authenticate.SESSION_TIMEOUT_KEY)).To(Equal(30))
+					Expect(session.Get(authenticate.SAVE_SESSION_ON_TIMEOUT_KEY)).To(BeTrue())
+					Expect(session.Get(authenticate.DELETE_SESSION_ON_TIMEOUT_KEY)).To(BeTrue())
+					Expect(session.Get(authenticate.SAVE_SESSION_ON_QUIT_KEY)).To(BeFalse())
+					Expect(session.Get(authenticate.DELETE_SESSION_ON_QUIT_KEY)).To(BeTrue())
+				})
+			})
+
+			Context("Login with a maximum session lifetime", func() {
+				BeforeEach(func() {
+					uacValue = validUAC
+					auth.UacKind = "uac"
+					mockBusApi := &mocks.BusApiInterface{}
+					auth.BusApi = mockBusApi
+					mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					auth.SessionMaxLifetime = 4 * time.Hour
+				})
+
+				It("uses the global lifetime", func() {
+					decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
+					Expect(decryptedToken.MaxLifetime).To(Equal(int64(4 * 60 * 60)))
+					Expect(decryptedToken.IssuedAt).To(BeNumerically("~", time.Now().Unix(), 2))
+				})
+
+				Context("and a lifetime for the instrument", func() {
+					BeforeEach(func() {
+						auth.InstrumentSessionMaxLifetimes = map[string]time.Duration{"fo*": 90 * time.Minute}
+					})
+
+					It("uses the instrument's lifetime", func() {
+						decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
+						Expect(decryptedToken.MaxLifetime).To(Equal(int64(90 * 60)))
+					})
+				})
+			})
+
+			Context("Login when the UAC already has an active session", func() {
+				BeforeEach(func() {
+					uacValue = validUAC
+					auth.UacKind = "uac"
+					mockBusApi := &mocks.BusApiInterface{}
+					auth.BusApi = mockBusApi
+					mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					auth.Sessions.Register(jwtCrypto.UacRef(validUAC), "other-session", time.Minute)
+				})
+
+				Context("and the policy is to evict it", func() {
+					It("logs in and replaces the other session", func() {
+						Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+						decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
+						Expect(decryptedToken.Id).ToNot(BeEmpty())
+						Expect(auth.Sessions.Active(decryptedToken.UacRef, decryptedToken.Id)).To(BeTrue())
+						Expect(auth.Sessions.Active(decryptedToken.UacRef, "other-session")).To(BeFalse())
+					})
+				})
+
+				Context("and the policy is to refuse", func() {
+					BeforeEach(func() {
+						auth.Sessions.(*sessionregistry.Registry).Policy = sessionregistry.POLICY_REFUSE
+					})
+
+					It("returns a status unauthorised and keeps the other session", func() {
+						Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+						Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+						Expect(auth.Sessions.Active(jwtCrypto.UacRef(validUAC), "other-session")).To(BeTrue())
+						Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Access code already in use"))
+					})
+				})
+			})
 		})
 
 		Context("Login with a valid UAC Code containing whitespace", func() {
@@ -250,7 +774,7 @@ var _ = Describe("Login", func() {
 					mockBusApi := &mocks.BusApiInterface{}
 					auth.BusApi = mockBusApi
 
-					mockBusApi.On("GetUacInfo", validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
 				})
 
 				It("redirects to /:instrumentName/", func() {
@@ -258,7 +782,7 @@ var _ = Describe("Login", func() {
 					Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
 					Expect(httpRecorder.Result().Cookies()).ToNot(BeEmpty())
 					decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
-					Expect(decryptedToken.UAC).To(Equal(validUAC))
+					Expect(decryptedToken.UacRef).To(Equal(jwtCrypto.UacRef(validUAC)))
 					Expect(decryptedToken.UacInfo.InstrumentName).To(Equal("foo"))
 					Expect(decryptedToken.UacInfo.CaseID).To(Equal("bar"))
 				})
@@ -271,7 +795,7 @@ var _ = Describe("Login", func() {
 					mockBusApi := &mocks.BusApiInterface{}
 					auth.BusApi = mockBusApi
 
-					mockBusApi.On("GetUacInfo", validUAC16).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+					mockBusApi.On("GetUacInfo", mock.Anything, normalisedUAC16).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
 				})
 
 				It("redirects to /:instrumentName/", func() {
@@ -279,7 +803,7 @@ var _ = Describe("Login", func() {
 					Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
 					Expect(httpRecorder.Result().Cookies()).ToNot(BeEmpty())
 					decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
-					Expect(decryptedToken.UAC).To(Equal(validUAC16))
+					Expect(decryptedToken.UacRef).To(Equal(jwtCrypto.UacRef(normalisedUAC16)))
 					Expect(decryptedToken.UacInfo.InstrumentName).To(Equal("foo"))
 					Expect(decryptedToken.UacInfo.CaseID).To(Equal("bar"))
 				})
@@ -324,6 +848,20 @@ var _ = Describe("Login", func() {
 					Expect(strings.Contains(string(body), `Enter your 16-character access code`)).To(BeTrue())
 				})
 			})
+
+			Context("Login with both UAC kinds accepted", func() {
+				BeforeEach(func() {
+					auth.UacKind = "both"
+				})
+
+				It("returns a status unauthorised with an error naming both lengths", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+					Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+					body := httpRecorder.Body.Bytes()
+					Expect(strings.Contains(string(body), `Enter your 12-digit or 16-character access code`)).To(BeTrue())
+					Expect(strings.Contains(string(body), `Enter your access code`)).To(BeTrue())
+				})
+			})
 		})
 
 		Context("Login with a long UAC Code", func() {
@@ -445,10 +983,15 @@ var _ = Describe("Login", func() {
 				SessionName: "session",
 			}
 			languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
-			auth                = &authenticate.Auth{CSRFManager: csrfManager, LanguageManager: languageManagerMock}
+			jwtCrypto           = &authenticate.JWTCrypto{JWTSecret: "hello"}
+			auth                = &authenticate.Auth{CSRFManager: csrfManager, LanguageManager: languageManagerMock, JWTCrypto: jwtCrypto}
+			uacRef              = jwtCrypto.UacRef("123456789012")
+			saveSessionOnQuit   bool
+			deleteSessionOnQuit bool
 		)
 
 		BeforeEach(func() {
+			saveSessionOnQuit, deleteSessionOnQuit = true, false
 			languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
 			httpRouter = gin.Default()
 			httpRouter.SetFuncMap(template.FuncMap{
@@ -457,9 +1000,15 @@ var _ = Describe("Login", func() {
 			httpRouter.LoadHTMLGlob("../templates/*")
 			store := cookie.NewStore([]byte("secret"))
 			httpRouter.Use(sessions.SessionsMany([]string{"session", "user_session", "session_validation"}, store))
+			auth.Sessions, _ = sessionregistry.NewRegistry(kvstore.NewMemoryStore(), sessionregistry.POLICY_EVICT)
 			httpRouter.GET("/logout", func(context *gin.Context) {
 				session = sessions.DefaultMany(context, "user_session")
 				session.Set("foobar", "fizzbuzz")
+				session.Set(authenticate.SAVE_SESSION_ON_QUIT_KEY, saveSessionOnQuit)
+				session.Set(authenticate.DELETE_SESSION_ON_QUIT_KEY, deleteSessionOnQuit)
+				signedToken, claim, _ := jwtCrypto.EncryptJWT("123456789012", &busapi.UacInfo{}, 15, 0)
+				auth.Sessions.Register(claim.UacRef, claim.Id, time.Minute)
+				session.Set(authenticate.JWT_TOKEN_KEY, signedToken)
 				session.Save()
 				Expect(session.Get("foobar")).ToNot(BeNil())
 				auth.Logout(context, session)
@@ -479,6 +1028,189 @@ var _ = Describe("Login", func() {
 				body := httpRecorder.Body.Bytes()
 				Expect(strings.Contains(string(body), `<h1>Your progress has been saved</h1>`)).To(BeTrue())
 			})
+
+			It("Revokes the UAC's session", func() {
+				activeSessionID, _ := auth.Sessions.(*sessionregistry.Registry).Store.Get("session_registry:" + uacRef)
+				Expect(activeSessionID).To(BeEmpty())
+			})
+		})
+
+		DescribeTable("tells the respondent what happened to their answers",
+			func(saveOnQuit, deleteOnQuit bool, heading string) {
+				saveSessionOnQuit, deleteSessionOnQuit = saveOnQuit, deleteOnQuit
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/logout", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(heading))
+			},
+			Entry("saved on quit", true, false, "<h1>Your progress has been saved</h1>"),
+			Entry("saved then deleted on quit", true, true, "<h1>Your progress has been saved</h1>"),
+			Entry("deleted without saving on quit", false, true, "<h1>Your answers have not been saved</h1>"),
+			Entry("neither saved nor deleted on quit", false, false, "<h1>You have signed out</h1>"),
+		)
+	})
+})
+
+var _ = Describe("LinkLogin", func() {
+	var (
+		validUAC  = "123456789012"
+		jwtCrypto = &authenticate.JWTCrypto{
+			JWTSecret: "hello",
+		}
+		linkTokenCrypto = &authenticate.LinkTokenCrypto{
+			LinkTokenSecret: "link-secret",
+		}
+		languageManagerMock *languageManagerMocks.LanguageManagerInterface
+		throttleMock        *throttleMocks.ThrottleInterface
+		mockBusApi          *mocks.BusApiInterface
+		auth                *authenticate.Auth
+		httpRouter          *gin.Engine
+		httpRecorder        *httptest.ResponseRecorder
+		session             sessions.Session
+		observedLogs        *observer.ObservedLogs
+		observedZapCore     zapcore.Core
+		linkToken           string
+	)
+
+	BeforeEach(func() {
+		observedZapCore, observedLogs = observer.New(zap.InfoLevel)
+		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
+		languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+		languageManagerMock.On("LanguageError", authenticate.INVALID_LINK_ERR, mock.Anything).Return(authenticate.INVALID_LINK_ERR["english"])
+		languageManagerMock.On("LanguageError", authenticate.USED_LINK_ERR, mock.Anything).Return(authenticate.USED_LINK_ERR["english"])
+		throttleMock = &throttleMocks.ThrottleInterface{}
+		throttleMock.On("Throttled", mock.Anything).Return(time.Duration(0))
+		throttleMock.On("RecordFailure", mock.Anything).Return()
+		mockBusApi = &mocks.BusApiInterface{}
+		mockRestApi := &mockrestapi.BlaiseRestApiInterface{}
+		mockRestApi.On("GetInstrumentSettings", mock.Anything, "foo").Return(blaiserestapi.InstrumentSettings{}, nil)
+		mockRestApi.On("GetCaseStatus", mock.Anything, "foo", mock.Anything).Return(blaiserestapi.CaseStatus{}, nil)
+		auth = &authenticate.Auth{
+			JWTCrypto:       jwtCrypto,
+			BusApi:          mockBusApi,
+			BlaiseRestApi:   mockRestApi,
+			Logger:          zap.New(observedZapCore),
+			CSRFManager:     &csrf.DefaultCSRFManager{Secret: "fwibble", SessionName: "session"},
+			LanguageManager: languageManagerMock,
+			Throttle:        throttleMock,
+			LinkTokens:      linkTokenCrypto,
+			LinkTokenStore:  kvstore.NewMemoryStore(),
+		}
+		auth.Sessions, _ = sessionregistry.NewRegistry(kvstore.NewMemoryStore(), sessionregistry.POLICY_EVICT)
+		httpRouter = gin.Default()
+		httpRouter.SetFuncMap(template.FuncMap{
+			"WrapWelsh": webserver.WrapWelsh,
+		})
+		httpRouter.LoadHTMLGlob("../templates/*")
+		store := cookie.NewStore([]byte("secret"))
+		httpRouter.Use(sessions.SessionsMany([]string{"session", "user_session", "session_validation", "language_session"}, store))
+		httpRouter.POST("/auth/link/:token", func(context *gin.Context) {
+			session = sessions.DefaultMany(context, "user_session")
+			auth.LinkLogin(context, session, context.Param("token"))
+		})
+
+		var err error
+		linkToken, err = linkTokenCrypto.EncryptLinkToken(validUAC, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	followLink := func(token string) {
+		httpRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/auth/link/%s", token), nil)
+		httpRouter.ServeHTTP(httpRecorder, req)
+	}
+
+	Context("with a valid link", func() {
+		BeforeEach(func() {
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+		})
+
+		It("logs in with the UAC from the link", func() {
+			followLink(linkToken)
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+			Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
+			decryptedToken, _ := auth.JWTCrypto.DecryptJWT(session.Get(authenticate.JWT_TOKEN_KEY))
+			Expect(decryptedToken.UacInfo.InstrumentName).To(Equal("foo"))
+			Expect(decryptedToken.UacInfo.CaseID).To(Equal("bar"))
+			mockBusApi.AssertExpectations(GinkgoT())
+		})
+
+		It("does not put the UAC in the link", func() {
+			Expect(linkToken).ToNot(ContainSubstring(validUAC))
+		})
+
+		It("only works once", func() {
+			followLink(linkToken)
+			followLink(linkToken)
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(authenticate.USED_LINK_ERR["english"]))
+			mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 1)
+
+			for _, logEntry := range observedLogs.All() {
+				Expect(fmt.Sprint(logEntry.ContextMap())).ToNot(ContainSubstring(validUAC))
+			}
+			lastLog := observedLogs.All()[observedLogs.Len()-1]
+			Expect(lastLog.ContextMap()["Reason"]).To(Equal("Link token already used"))
+		})
+	})
+
+	Context("when the first login with a link fails", func() {
+		BeforeEach(func() {
+			languageManagerMock.On("LanguageError", authenticate.UAC_CHECK_UNAVAILABLE_ERR, mock.Anything).Return(authenticate.UAC_CHECK_UNAVAILABLE_ERR["english"])
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{}, &upstream.UnavailableError{Upstream: busapi.UPSTREAM})
+			mockBusApi.On("GetUacInfo", mock.Anything, validUAC).Once().Return(busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}, nil)
+		})
+
+		It("can be retried with the same link", func() {
+			followLink(linkToken)
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(session.Get(authenticate.JWT_TOKEN_KEY)).To(BeNil())
+
+			followLink(linkToken)
+			Expect(httpRecorder.Code).To(Equal(http.StatusFound))
+			Expect(httpRecorder.Header()["Location"]).To(Equal([]string{"/foo/"}))
+			mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 2)
+		})
+
+		It("is used up by the successful retry", func() {
+			followLink(linkToken)
+			followLink(linkToken)
+			followLink(linkToken)
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(authenticate.USED_LINK_ERR["english"]))
+			mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 2)
+		})
+	})
+
+	Context("with a link that has been tampered with", func() {
+		It("returns a status unauthorised with an invalid link error", func() {
+			followLink(linkToken + "x")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(authenticate.INVALID_LINK_ERR["english"]))
+			Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Invalid link token"))
+			throttleMock.AssertCalled(GinkgoT(), "RecordFailure", mock.Anything)
+			mockBusApi.AssertNotCalled(GinkgoT(), "GetUacInfo", mock.Anything, mock.Anything)
+		})
+	})
+
+	Context("when link login is not configured", func() {
+		BeforeEach(func() {
+			auth.LinkTokens = nil
+		})
+
+		It("returns a status unauthorised with an invalid link error", func() {
+			followLink(linkToken)
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(authenticate.INVALID_LINK_ERR["english"]))
+			mockBusApi.AssertNotCalled(GinkgoT(), "GetUacInfo", mock.Anything, mock.Anything)
 		})
 	})
 })
@@ -493,18 +1225,24 @@ var _ = Describe("AuthenticatedWithUac", func() {
 			SessionName: "session",
 		}
 		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
+		mockRegistry        = &registryMocks.RegistryInterface{}
 		auth                = &authenticate.Auth{
 			JWTCrypto:       mockJwtCrypto,
 			CSRFManager:     csrfManager,
 			LanguageManager: languageManagerMock,
+			Sessions:        mockRegistry,
+			Logger:          zap.NewNop(),
 		}
-		httpRecorder *httptest.ResponseRecorder
-		httpRouter   *gin.Engine
-		sessionValid = false
+		httpRecorder         *httptest.ResponseRecorder
+		httpRouter           *gin.Engine
+		sessionValid         = false
+		saveSessionOnTimeout = false
+		claim                = &authenticate.UACClaims{UacRef: "uac-ref", StandardClaims: jwt.StandardClaims{Id: "session-id"}}
 	)
 
 	BeforeEach(func() {
 		languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+		languageManagerMock.On("LanguageError", authenticate.SESSION_REPLACED_ERR, mock.Anything).Return(authenticate.SESSION_REPLACED_ERR["english"])
 		httpRouter = gin.Default()
 		httpRouter.SetFuncMap(template.FuncMap{
 			"WrapWelsh": webserver.WrapWelsh,
@@ -520,6 +1258,8 @@ var _ = Describe("AuthenticatedWithUac", func() {
 		auth.JWTCrypto = mockJwtCrypto
 		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
 		auth.LanguageManager = languageManagerMock
+		mockRegistry = &registryMocks.RegistryInterface{}
+		auth.Sessions = mockRegistry
 	})
 
 	JustBeforeEach(func() {
@@ -533,6 +1273,7 @@ var _ = Describe("AuthenticatedWithUac", func() {
 			httpRouter.Use(func(context *gin.Context) {
 				session = sessions.DefaultMany(context, "user_session")
 				session.Set(authenticate.JWT_TOKEN_KEY, "foobar")
+				session.Set(authenticate.SAVE_SESSION_ON_TIMEOUT_KEY, saveSessionOnTimeout)
 				session.Save()
 
 				sessionValidation := sessions.DefaultMany(context, "session_validation")
@@ -549,12 +1290,13 @@ var _ = Describe("AuthenticatedWithUac", func() {
 
 		Context("When a token can be decrypted", func() {
 			BeforeEach(func() {
-				mockJwtCrypto.On("DecryptJWT", mock.Anything).Return(nil, nil)
+				mockJwtCrypto.On("DecryptJWT", mock.Anything).Return(claim, nil)
 			})
 
 			Context("and the session is valid", func() {
 				BeforeEach(func() {
 					sessionValid = true
+					mockRegistry.On("Active", "uac-ref", "session-id").Return(true, nil)
 				})
 
 				It("Allows the context to continue", func() {
@@ -564,6 +1306,78 @@ var _ = Describe("AuthenticatedWithUac", func() {
 				})
 			})
 
+			Context("and the session has reached its maximum lifetime", func() {
+				BeforeEach(func() {
+					sessionValid = true
+					claim.MaxLifetime = 3600
+					claim.IssuedAt = time.Now().Add(-2 * time.Hour).Unix()
+					mockRegistry.On("Active", "uac-ref", "session-id").Return(true, nil)
+					mockRegistry.On("Revoke", "uac-ref").Return(nil)
+				})
+
+				AfterEach(func() {
+					claim.MaxLifetime = 0
+					claim.IssuedAt = 0
+				})
+
+				It("ends the session and says why", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+					body := httpRecorder.Body.String()
+					Expect(body).To(ContainSubstring(`Your session has ended`))
+					Expect(body).To(ContainSubstring(`you can only stay signed in to your study for 60 minutes at a time`))
+					mockRegistry.AssertCalled(GinkgoT(), "Revoke", "uac-ref")
+				})
+
+				It("does not say the answers were saved unless they were", func() {
+					Expect(httpRecorder.Body.String()).ToNot(ContainSubstring(`Your answers have been saved`))
+				})
+
+				Context("and the instrument saves answers on timeout", func() {
+					BeforeEach(func() {
+						saveSessionOnTimeout = true
+					})
+
+					AfterEach(func() {
+						saveSessionOnTimeout = false
+					})
+
+					It("says the answers were saved", func() {
+						Expect(httpRecorder.Body.String()).To(ContainSubstring(`Your answers have been saved`))
+					})
+				})
+			})
+
+			Context("and the session has been replaced by another login", func() {
+				BeforeEach(func() {
+					sessionValid = true
+					mockRegistry.On("Active", "uac-ref", "session-id").Return(false, nil)
+				})
+
+				It("returns unauthorized with an explanation", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+					body := httpRecorder.Body.Bytes()
+					Expect(string(body)).To(ContainSubstring(authenticate.SESSION_REPLACED_ERR["english"]))
+				})
+			})
+
+			Context("and the session was started before sessions were registered", func() {
+				BeforeEach(func() {
+					sessionValid = true
+					claim.UacRef = ""
+					claim.Id = ""
+				})
+
+				AfterEach(func() {
+					claim.UacRef = "uac-ref"
+					claim.Id = "session-id"
+				})
+
+				It("Allows the context to continue", func() {
+					Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+					mockRegistry.AssertNotCalled(GinkgoT(), "Active", mock.Anything, mock.Anything)
+				})
+			})
+
 			Context("and the session is invalid", func() {
 				BeforeEach(func() {
 					sessionValid = false
@@ -612,9 +1426,11 @@ var _ = Describe("Has Session", func() {
 
 		mockJwtCrypto       = &mockauth.JWTCryptoInterface{}
 		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
+		mockRegistry        = &registryMocks.RegistryInterface{}
 		auth                = &authenticate.Auth{
 			JWTCrypto:       mockJwtCrypto,
 			LanguageManager: languageManagerMock,
+			Sessions:        mockRegistry,
 		}
 		httpRecorder   *httptest.ResponseRecorder
 		httpRouter     *gin.Engine
@@ -655,6 +1471,8 @@ var _ = Describe("Has Session", func() {
 	AfterEach(func() {
 		mockJwtCrypto = &mockauth.JWTCryptoInterface{}
 		auth.JWTCrypto = mockJwtCrypto
+		mockRegistry = &registryMocks.RegistryInterface{}
+		auth.Sessions = mockRegistry
 	})
 
 	JustBeforeEach(func() {
@@ -677,9 +1495,29 @@ var _ = Describe("Has Session", func() {
 			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
 			body := httpRecorder.Body.Bytes()
 			Expect(string(body)).To(Equal(
-				`{"HasSession":true,"Claim":{"uac":"","auth_timeout":0,"instrument_name":"foobar","case_id":"fizzbuzz"}}`,
+				`{"HasSession":true,"Claim":{"uac_ref":"","auth_timeout":0,"instrument_name":"foobar","case_id":"fizzbuzz"}}`,
 			))
 		})
+
+		It("treats a session started before sessions were registered as active", func() {
+			mockRegistry.AssertNotCalled(GinkgoT(), "Active", mock.Anything, mock.Anything)
+		})
+	})
+
+	Context("When someone's session has been replaced by another login", func() {
+		BeforeEach(func() {
+			mockJwtCrypto.On("DecryptJWT", mock.Anything).Return(&authenticate.UACClaims{
+				UacRef:         "uac-ref",
+				StandardClaims: jwt.StandardClaims{Id: "session-id"},
+			}, nil)
+			mockRegistry.On("Active", "uac-ref", "session-id").Return(false, nil)
+		})
+
+		It("returns false and an empty claim", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			body := httpRecorder.Body.Bytes()
+			Expect(string(body)).To(Equal(`{"HasSession":false,"Claim":null}`))
+		})
 	})
 
 	Context("When someone doesn't have a session", func() {
@@ -0,0 +1,85 @@
+package authenticate
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	CHECKSUM_NONE    = "none"
+	CHECKSUM_LUHN    = "luhn"
+	CHECKSUM_ISO7064 = "iso7064"
+)
+
+// ChecksumInterface checks the check character of a code made up of
+// characters from alphabet, where each character's value is its position in
+// the alphabet
+type ChecksumInterface interface {
+	Valid(string, string) bool
+}
+
+func ChecksumFromName(name string) (ChecksumInterface, error) {
+	switch strings.ToLower(name) {
+	case CHECKSUM_NONE, "":
+		return NoChecksum{}, nil
+	case CHECKSUM_LUHN:
+		return LuhnChecksum{}, nil
+	case CHECKSUM_ISO7064:
+		return ISO7064Checksum{}, nil
+	}
+	return nil, fmt.Errorf("unknown UAC checksum algorithm %q", name)
+}
+
+type NoChecksum struct{}
+
+func (NoChecksum) Valid(code, alphabet string) bool {
+	return true
+}
+
+// LuhnChecksum is the Luhn mod N algorithm, which is the standard Luhn
+// algorithm for a numeric alphabet
+type LuhnChecksum struct{}
+
+func (LuhnChecksum) Valid(code, alphabet string) bool {
+	base := len(alphabet)
+	if base < 2 || code == "" {
+		return false
+	}
+	factor := 1
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		value := strings.IndexByte(alphabet, code[i])
+		if value < 0 {
+			return false
+		}
+		addend := factor * value
+		sum += addend/base + addend%base
+		factor = 3 - factor
+	}
+	return sum%base == 0
+}
+
+// ISO7064Checksum is the ISO 7064 hybrid system MOD (N+1, N), e.g. MOD 11,10
+// for a numeric alphabet
+type ISO7064Checksum struct{}
+
+func (ISO7064Checksum) Valid(code, alphabet string) bool {
+	modulus := len(alphabet)
+	if modulus < 2 || code == "" {
+		return false
+	}
+	product := modulus
+	sum := 0
+	for i := 0; i < len(code); i++ {
+		value := strings.IndexByte(alphabet, code[i])
+		if value < 0 {
+			return false
+		}
+		sum = (product + value) % modulus
+		if sum == 0 {
+			sum = modulus
+		}
+		product = (2 * sum) % (modulus + 1)
+	}
+	return sum == 1
+}
@@ -2,6 +2,7 @@ package authenticate
 
 import (
 	"strings"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/busapi"
 	"github.com/golang-jwt/jwt"
@@ -9,8 +10,13 @@ import (
 )
 
 type UACClaims struct {
-	UAC         string `json:"uac"`
+	UacRef      string `json:"uac_ref"`
 	AuthTimeout int    `json:"auth_timeout"`
+	// MaxLifetime is how many seconds after it was first issued, in IssuedAt,
+	// the session ends however active it is. Zero means it never does.
+	MaxLifetime int64 `json:"max_lifetime,omitempty"`
+	// KeyID is the kid of the key that verified the token, set by DecryptJWT
+	KeyID string `json:"-"`
 	busapi.UacInfo
 	jwt.StandardClaims
 }
@@ -23,10 +29,33 @@ func (uacClaims *UACClaims) AuthenticatedForCase(caseID string) bool {
 	return strings.EqualFold(uacClaims.UacInfo.CaseID, caseID)
 }
 
+// Registered reports whether the session is in the session registry. Tokens
+// issued before sessions were registered have no UacRef or ID.
+func (uacClaims *UACClaims) Registered() bool {
+	return uacClaims.UacRef != "" && uacClaims.Id != ""
+}
+
+// LifetimeEnded reports whether the session has reached its maximum
+// lifetime. Refreshing the token keeps the original issue time, so this
+// cannot be put off by staying active.
+func (uacClaims *UACClaims) LifetimeEnded(now time.Time) bool {
+	if uacClaims.MaxLifetime <= 0 {
+		return false
+	}
+	return now.Unix() >= uacClaims.IssuedAt+uacClaims.MaxLifetime
+}
+
+// MaxLifetimeMinutes is the maximum lifetime rounded to the nearest minute,
+// for telling respondents about it
+func (uacClaims *UACClaims) MaxLifetimeMinutes() int {
+	return int((uacClaims.MaxLifetime + 30) / 60)
+}
+
 func (uacClaims *UACClaims) LogFields() []zap.Field {
 	var fields []zap.Field
 	fields = append(fields, zap.String("AuthedInstrumentName", uacClaims.UacInfo.InstrumentName))
 	fields = append(fields, zap.String("AuthedCaseID", uacClaims.UacInfo.CaseID))
 	fields = append(fields, zap.Int("AuthTimeout", uacClaims.AuthTimeout))
+	fields = append(fields, zap.String("JWTKeyID", uacClaims.KeyID))
 	return fields
 }
@@ -2,6 +2,7 @@ package authenticate_test
 
 import (
 	"strings"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
 	"github.com/ONSdigital/blaise-cawi-portal/busapi"
@@ -16,7 +17,7 @@ var _ = Describe("Claims", func() {
 		instrumentName = "foo"
 		caseID         = "bar"
 		claim          = &authenticate.UACClaims{
-			UAC:         "0008901",
+			UacRef:      "0008901",
 			AuthTimeout: 15,
 			UacInfo: busapi.UacInfo{
 				InstrumentName: instrumentName,
@@ -43,6 +44,22 @@ var _ = Describe("Claims", func() {
 		Entry("not matching", "bacon", false),
 	)
 
+	Describe("LifetimeEnded", func() {
+		var issuedAt = time.Date(2022, 1, 1, 9, 0, 0, 0, time.UTC)
+
+		DescribeTable("compares the original issue time with the maximum lifetime",
+			func(maxLifetime int64, now time.Time, expected bool) {
+				lifetimeClaim := &authenticate.UACClaims{MaxLifetime: maxLifetime}
+				lifetimeClaim.IssuedAt = issuedAt.Unix()
+				Expect(lifetimeClaim.LifetimeEnded(now)).To(Equal(expected))
+			},
+			Entry("within the lifetime", int64(3600), issuedAt.Add(59*time.Minute), false),
+			Entry("at the end of the lifetime", int64(3600), issuedAt.Add(time.Hour), true),
+			Entry("after the lifetime", int64(3600), issuedAt.Add(2*time.Hour), true),
+			Entry("no maximum lifetime", int64(0), issuedAt.Add(100*time.Hour), false),
+		)
+	})
+
 	Describe("LogFields", func() {
 		It("Returns the instrument name and case ID as log fields", func() {
 			fields := claim.LogFields()
@@ -1,6 +1,9 @@
 package authenticate
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -11,44 +14,99 @@ import (
 //Generate mocks by running "go generate ./..."
 //go:generate mockery --name JWTCryptoInterface
 type JWTCryptoInterface interface {
-	EncryptJWT(string, *busapi.UacInfo, int) (string, error)
+	EncryptJWT(string, *busapi.UacInfo, int, time.Duration) (string, *UACClaims, error)
+	RefreshJWT(*UACClaims) (string, error)
 	DecryptJWT(interface{}) (*UACClaims, error)
 }
 
+// JWTCrypto signs session tokens with the active key in KeySet and verifies
+// them against any key in it. With no KeySet, JWTSecret is used on its own.
+// Tokens never carry the UAC, only a keyed hash of it made with UacHashSecret.
 type JWTCrypto struct {
-	JWTSecret string
+	JWTSecret     string
+	KeySet        JWTKeySet
+	UacHashSecret string
 }
 
 var DefaultAuthTimeout = 15
 
-func (jwtCrypto *JWTCrypto) EncryptJWT(uac string, uacInfo *busapi.UacInfo, authTimeout int) (string, error) {
-	if authTimeout == 0 {
-		authTimeout = DefaultAuthTimeout
+// EncryptJWT starts a new session token for a UAC, returning the signed token
+// and its claims. Each token gets a unique ID. A maxLifetime of zero lets the
+// session last for as long as it is kept active.
+func (jwtCrypto *JWTCrypto) EncryptJWT(uac string, uacInfo *busapi.UacInfo, authTimeout int, maxLifetime time.Duration) (string, *UACClaims, error) {
+	sessionID, err := randomHex(16)
+	if err != nil {
+		return "", nil, err
 	}
-
-	claims := UACClaims{
-		UAC:         uac,
+	claims := &UACClaims{
+		UacRef:      jwtCrypto.UacRef(uac),
 		AuthTimeout: authTimeout,
+		MaxLifetime: int64(maxLifetime.Seconds()),
 		UacInfo: busapi.UacInfo{
 			InstrumentName: uacInfo.InstrumentName,
 			CaseID:         uacInfo.CaseID,
 		},
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Unix() + expirationSeconds(authTimeout),
-			Issuer:    ISSUER,
+			Id:       sessionID,
+			IssuedAt: time.Now().Unix(),
 		},
 	}
+	signedToken, err := jwtCrypto.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signedToken, claims, nil
+}
+
+// RefreshJWT re-signs existing claims with a fresh expiry, using the active key
+func (jwtCrypto *JWTCrypto) RefreshJWT(claims *UACClaims) (string, error) {
+	refreshedClaims := *claims
+	return jwtCrypto.sign(&refreshedClaims)
+}
 
+// UacRef is the opaque reference to a UAC carried in the token in place of
+// the UAC itself
+func (jwtCrypto *JWTCrypto) UacRef(uac string) string {
+	mac := hmac.New(sha256.New, []byte(jwtCrypto.UacHashSecret))
+	mac.Write([]byte(uac))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (jwtCrypto *JWTCrypto) sign(claims *UACClaims) (string, error) {
+	if claims.AuthTimeout == 0 {
+		claims.AuthTimeout = DefaultAuthTimeout
+	}
+	claims.ExpiresAt = time.Now().Unix() + expirationSeconds(claims.AuthTimeout)
+	claims.Issuer = ISSUER
+
+	key := jwtCrypto.keySet().Active()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtCrypto.JWTSecret))
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString([]byte(key.Secret))
 }
 
 func (jwtCrypto *JWTCrypto) DecryptJWT(jwtToken interface{}) (*UACClaims, error) {
 	if jwtToken == nil {
 		return nil, fmt.Errorf("no JWT Token in session")
 	}
+	var keyID string
 	token, err := jwt.ParseWithClaims(jwtToken.(string), &UACClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtCrypto.JWTSecret), nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		if kid, found := token.Header["kid"]; found {
+			var ok bool
+			if keyID, ok = kid.(string); !ok {
+				return nil, fmt.Errorf("invalid kid header")
+			}
+		}
+		key, found := jwtCrypto.keySet().Find(keyID)
+		if !found {
+			return nil, fmt.Errorf("unknown or retired signing key %q", keyID)
+		}
+		return []byte(key.Secret), nil
 	})
 	if err != nil {
 		return nil, err
@@ -57,7 +115,16 @@ func (jwtCrypto *JWTCrypto) DecryptJWT(jwtToken interface{}) (*UACClaims, error)
 		return nil, err
 	}
 
-	return token.Claims.(*UACClaims), nil
+	claims := token.Claims.(*UACClaims)
+	claims.KeyID = keyID
+	return claims, nil
+}
+
+func (jwtCrypto *JWTCrypto) keySet() JWTKeySet {
+	if len(jwtCrypto.KeySet) == 0 {
+		return JWTKeySet{{Secret: jwtCrypto.JWTSecret}}
+	}
+	return jwtCrypto.KeySet
 }
 
 func expirationSeconds(sessionTimeout int) int64 {
@@ -0,0 +1,76 @@
+package authenticate_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JWTCrypto", func() {
+	var (
+		uac       = "123456789012"
+		uacInfo   = &busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}
+		jwtCrypto = &authenticate.JWTCrypto{
+			JWTSecret:     "hello",
+			UacHashSecret: "pepper",
+		}
+	)
+
+	Describe("EncryptJWT", func() {
+		It("does not put the UAC in the token", func() {
+			token, _, err := jwtCrypto.EncryptJWT(uac, uacInfo, 15, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(payload)).ToNot(ContainSubstring(uac))
+
+			claims, err := jwtCrypto.DecryptJWT(token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claims.UacRef).To(Equal(jwtCrypto.UacRef(uac)))
+		})
+	})
+
+	Describe("UacRef", func() {
+		It("depends on the hash secret", func() {
+			otherCrypto := &authenticate.JWTCrypto{UacHashSecret: "salt"}
+			Expect(jwtCrypto.UacRef(uac)).To(Equal(jwtCrypto.UacRef(uac)))
+			Expect(jwtCrypto.UacRef(uac)).ToNot(Equal(otherCrypto.UacRef(uac)))
+		})
+	})
+
+	Describe("RefreshJWT", func() {
+		It("keeps the claims", func() {
+			token, _, _ := jwtCrypto.EncryptJWT(uac, uacInfo, 30, 0)
+			claims, _ := jwtCrypto.DecryptJWT(token)
+
+			refreshedToken, err := jwtCrypto.RefreshJWT(claims)
+			Expect(err).ToNot(HaveOccurred())
+
+			refreshedClaims, err := jwtCrypto.DecryptJWT(refreshedToken)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refreshedClaims.UacRef).To(Equal(claims.UacRef))
+			Expect(refreshedClaims.UacInfo).To(Equal(claims.UacInfo))
+			Expect(refreshedClaims.AuthTimeout).To(Equal(30))
+		})
+
+		It("keeps the original issue time and maximum lifetime", func() {
+			token, _, _ := jwtCrypto.EncryptJWT(uac, uacInfo, 15, 2*time.Hour)
+			claims, _ := jwtCrypto.DecryptJWT(token)
+			claims.IssuedAt -= 3600
+
+			refreshedToken, _ := jwtCrypto.RefreshJWT(claims)
+			refreshedClaims, err := jwtCrypto.DecryptJWT(refreshedToken)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(refreshedClaims.IssuedAt).To(Equal(claims.IssuedAt))
+			Expect(refreshedClaims.MaxLifetime).To(Equal(int64(7200)))
+			Expect(refreshedClaims.ExpiresAt).To(BeNumerically(">", claims.IssuedAt+3600))
+		})
+	})
+})
@@ -0,0 +1,96 @@
+package authenticate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// JWTKey is a signing key, identified in the token's kid header by ID. A key
+// with an empty ID signs tokens without a kid, as tokens were before keys were
+// rotated.
+type JWTKey struct {
+	ID     string
+	Secret string
+}
+
+// JWTKeySet is an ordered list of signing keys. The first key is the active
+// one new tokens are signed with; any key in the set will verify a token.
+// Retire a key by removing it from the set.
+type JWTKeySet []JWTKey
+
+func (keySet JWTKeySet) Active() JWTKey {
+	return keySet[0]
+}
+
+func (keySet JWTKeySet) Find(id string) (JWTKey, bool) {
+	for _, key := range keySet {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return JWTKey{}, false
+}
+
+// ParseJWTKeySet parses "kid:secret" entries separated by commas or new
+// lines. Blank lines and lines starting with # are ignored.
+func ParseJWTKeySet(keys string) (JWTKeySet, error) {
+	var keySet JWTKeySet
+	for _, line := range strings.Split(keys, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			parts := strings.SplitN(entry, ":", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("JWT key entries must be in the form kid:secret")
+			}
+			if _, found := keySet.Find(parts[0]); found {
+				return nil, fmt.Errorf("duplicate JWT key id %q", parts[0])
+			}
+			keySet = append(keySet, JWTKey{ID: parts[0], Secret: parts[1]})
+		}
+	}
+	return keySet, nil
+}
+
+// LoadJWTKeySet builds the keyset from the keys in env, followed by the keys
+// in keysFile. A legacy secret is added last, without a kid, so sessions
+// signed before rotation was set up keep working until it is removed.
+func LoadJWTKeySet(keys, keysFile, legacySecret string) (JWTKeySet, error) {
+	keySet, err := ParseJWTKeySet(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if keysFile != "" {
+		fileContents, err := ioutil.ReadFile(keysFile)
+		if err != nil {
+			return nil, err
+		}
+		fileKeySet, err := ParseJWTKeySet(string(fileContents))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keysFile, err)
+		}
+		for _, key := range fileKeySet {
+			if _, found := keySet.Find(key.ID); found {
+				return nil, fmt.Errorf("duplicate JWT key id %q", key.ID)
+			}
+			keySet = append(keySet, key)
+		}
+	}
+
+	if legacySecret != "" {
+		keySet = append(keySet, JWTKey{Secret: legacySecret})
+	}
+
+	if len(keySet) == 0 {
+		return nil, fmt.Errorf("no JWT signing keys configured")
+	}
+	return keySet, nil
+}
@@ -0,0 +1,132 @@
+package authenticate_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JWTKeySet", func() {
+	Describe("ParseJWTKeySet", func() {
+		It("parses comma separated keys in order", func() {
+			keySet, err := authenticate.ParseJWTKeySet("new:secret2, old:secret:1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keySet).To(Equal(authenticate.JWTKeySet{
+				{ID: "new", Secret: "secret2"},
+				{ID: "old", Secret: "secret:1"},
+			}))
+			Expect(keySet.Active().ID).To(Equal("new"))
+		})
+
+		It("parses keys one per line, skipping comments", func() {
+			keySet, err := authenticate.ParseJWTKeySet("# rotated 2022-02\nnew:secret2\n\nold:secret1\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keySet).To(HaveLen(2))
+		})
+
+		It("rejects entries without a kid", func() {
+			_, err := authenticate.ParseJWTKeySet("secret")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects duplicate kids", func() {
+			_, err := authenticate.ParseJWTKeySet("a:one,a:two")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("LoadJWTKeySet", func() {
+		var keysFile string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile("", "jwt-keys")
+			Expect(err).ToNot(HaveOccurred())
+			file.WriteString("file:secret3\n")
+			file.Close()
+			keysFile = file.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(keysFile)
+		})
+
+		It("puts env keys before file keys and the legacy secret last", func() {
+			keySet, err := authenticate.LoadJWTKeySet("env:secret4", keysFile, "legacy")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keySet).To(Equal(authenticate.JWTKeySet{
+				{ID: "env", Secret: "secret4"},
+				{ID: "file", Secret: "secret3"},
+				{ID: "", Secret: "legacy"},
+			}))
+		})
+
+		It("errors when there are no keys", func() {
+			_, err := authenticate.LoadJWTKeySet("", "", "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("errors when the file is missing", func() {
+			_, err := authenticate.LoadJWTKeySet("", "/does/not/exist", "legacy")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("JWTCrypto with a keyset", func() {
+		var (
+			uacInfo   = &busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}
+			oldCrypto = &authenticate.JWTCrypto{KeySet: authenticate.JWTKeySet{
+				{ID: "old", Secret: "secret1"},
+				{Secret: "legacy"},
+			}}
+			rotatedCrypto = &authenticate.JWTCrypto{KeySet: authenticate.JWTKeySet{
+				{ID: "new", Secret: "secret2"},
+				{ID: "old", Secret: "secret1"},
+			}}
+			retiredCrypto = &authenticate.JWTCrypto{KeySet: authenticate.JWTKeySet{
+				{ID: "new", Secret: "secret2"},
+			}}
+			legacyCrypto = &authenticate.JWTCrypto{JWTSecret: "legacy"}
+		)
+
+		It("signs with the active key and reports it when verifying", func() {
+			token, _, err := rotatedCrypto.EncryptJWT("123456789012", uacInfo, 15, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			claims, err := rotatedCrypto.DecryptJWT(token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claims.KeyID).To(Equal("new"))
+		})
+
+		It("verifies tokens signed with an older key still in the set", func() {
+			token, _, _ := oldCrypto.EncryptJWT("123456789012", uacInfo, 15, 0)
+
+			claims, err := rotatedCrypto.DecryptJWT(token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claims.KeyID).To(Equal("old"))
+			Expect(claims.UacInfo.InstrumentName).To(Equal("foo"))
+		})
+
+		It("rejects tokens signed with a retired key", func() {
+			token, _, _ := oldCrypto.EncryptJWT("123456789012", uacInfo, 15, 0)
+
+			_, err := retiredCrypto.DecryptJWT(token)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("verifies tokens without a kid against the legacy secret", func() {
+			token, _, _ := legacyCrypto.EncryptJWT("123456789012", uacInfo, 15, 0)
+
+			claims, err := oldCrypto.DecryptJWT(token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(claims.KeyID).To(BeEmpty())
+
+			_, err = rotatedCrypto.DecryptJWT(token)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
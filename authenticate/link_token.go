@@ -0,0 +1,166 @@
+package authenticate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const LINK_TOKEN_KEY_PREFIX = "link_token"
+
+var (
+	InvalidLinkTokenError = errors.New("invalid link token")
+)
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name LinkTokenCryptoInterface
+type LinkTokenCryptoInterface interface {
+	EncryptLinkToken(string, time.Duration) (string, error)
+	DecryptLinkToken(string) (*LinkToken, error)
+}
+
+// LinkToken is a verified one-time login link. ID is unique to the link and
+// is what gets recorded once the link has been used.
+type LinkToken struct {
+	ID        string
+	UAC       string
+	ExpiresAt time.Time
+}
+
+// linkClaims carry the UAC encrypted as an opaque reference so that the link,
+// which ends up in URLs and access logs, never contains the UAC itself
+type linkClaims struct {
+	UacRef string `json:"ref"`
+	jwt.StandardClaims
+}
+
+type LinkTokenCrypto struct {
+	LinkTokenSecret string
+}
+
+func (linkTokenCrypto *LinkTokenCrypto) EncryptLinkToken(uac string, ttl time.Duration) (string, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	uacRef, err := linkTokenCrypto.sealUac(uac, id)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := linkClaims{
+		UacRef: uacRef,
+		StandardClaims: jwt.StandardClaims{
+			Id:        id,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+			Issuer:    ISSUER,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(linkTokenCrypto.key("signing"))
+}
+
+func (linkTokenCrypto *LinkTokenCrypto) DecryptLinkToken(linkToken string) (*LinkToken, error) {
+	token, err := jwt.ParseWithClaims(linkToken, &linkClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return linkTokenCrypto.key("signing"), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(*linkClaims)
+	now := time.Now().Unix()
+	if claims.Id == "" || !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuer(ISSUER, true) {
+		return nil, InvalidLinkTokenError
+	}
+
+	uac, err := linkTokenCrypto.openUac(claims.UacRef, claims.Id)
+	if err != nil {
+		return nil, InvalidLinkTokenError
+	}
+
+	return &LinkToken{
+		ID:        claims.Id,
+		UAC:       uac,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// sealUac encrypts the UAC, binding it to the link ID so a reference cannot
+// be lifted from one link into another
+func (linkTokenCrypto *LinkTokenCrypto) sealUac(uac, id string) (string, error) {
+	aead, err := linkTokenCrypto.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(uac), []byte(id))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (linkTokenCrypto *LinkTokenCrypto) openUac(uacRef, id string) (string, error) {
+	aead, err := linkTokenCrypto.aead()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(uacRef)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", InvalidLinkTokenError
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	uac, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return string(uac), nil
+}
+
+func (linkTokenCrypto *LinkTokenCrypto) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(linkTokenCrypto.key("encryption"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// key derives separate signing and encryption keys from the one configured
+// secret
+func (linkTokenCrypto *LinkTokenCrypto) key(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(linkTokenCrypto.LinkTokenSecret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func LinkTokenKey(id string) string {
+	return fmt.Sprintf("%s:%s:used", LINK_TOKEN_KEY_PREFIX, id)
+}
+
+func randomHex(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := io.ReadFull(rand.Reader, bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,56 @@
+package authenticate_test
+
+import (
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LinkTokenCrypto", func() {
+	var linkTokenCrypto = &authenticate.LinkTokenCrypto{
+		LinkTokenSecret: "link-secret",
+	}
+
+	It("round trips a UAC", func() {
+		linkToken, err := linkTokenCrypto.EncryptLinkToken("123456789012", time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+
+		link, err := linkTokenCrypto.DecryptLinkToken(linkToken)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(link.UAC).To(Equal("123456789012"))
+		Expect(link.ID).ToNot(BeEmpty())
+		Expect(link.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), 2*time.Second))
+	})
+
+	It("gives each link a different ID", func() {
+		firstToken, _ := linkTokenCrypto.EncryptLinkToken("123456789012", time.Hour)
+		secondToken, _ := linkTokenCrypto.EncryptLinkToken("123456789012", time.Hour)
+		first, _ := linkTokenCrypto.DecryptLinkToken(firstToken)
+		second, _ := linkTokenCrypto.DecryptLinkToken(secondToken)
+		Expect(first.ID).ToNot(Equal(second.ID))
+	})
+
+	It("rejects an expired link", func() {
+		linkToken, _ := linkTokenCrypto.EncryptLinkToken("123456789012", -time.Minute)
+		_, err := linkTokenCrypto.DecryptLinkToken(linkToken)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects a link signed with another secret", func() {
+		otherCrypto := &authenticate.LinkTokenCrypto{LinkTokenSecret: "other-secret"}
+		linkToken, _ := otherCrypto.EncryptLinkToken("123456789012", time.Hour)
+		_, err := linkTokenCrypto.DecryptLinkToken(linkToken)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects a session JWT", func() {
+		jwtCrypto := &authenticate.JWTCrypto{JWTSecret: "link-secret"}
+		sessionToken, _, _ := jwtCrypto.EncryptJWT("123456789012", &busapi.UacInfo{}, 15, 0)
+		_, err := linkTokenCrypto.DecryptLinkToken(sessionToken)
+		Expect(err).To(HaveOccurred())
+	})
+})
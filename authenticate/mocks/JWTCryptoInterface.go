@@ -7,6 +7,8 @@ import (
 	busapi "github.com/ONSdigital/blaise-cawi-portal/busapi"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // JWTCryptoInterface is an autogenerated mock type for the JWTCryptoInterface type
@@ -37,20 +39,50 @@ func (_m *JWTCryptoInterface) DecryptJWT(_a0 interface{}) (*authenticate.UACClai
 	return r0, r1
 }
 
-// EncryptJWT provides a mock function with given fields: _a0, _a1, _a2
-func (_m *JWTCryptoInterface) EncryptJWT(_a0 string, _a1 *busapi.UacInfo, _a2 int) (string, error) {
-	ret := _m.Called(_a0, _a1, _a2)
+// EncryptJWT provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *JWTCryptoInterface) EncryptJWT(_a0 string, _a1 *busapi.UacInfo, _a2 int, _a3 time.Duration) (string, *authenticate.UACClaims, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(string, *busapi.UacInfo, int) string); ok {
-		r0 = rf(_a0, _a1, _a2)
+	if rf, ok := ret.Get(0).(func(string, *busapi.UacInfo, int, time.Duration) string); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 *authenticate.UACClaims
+	if rf, ok := ret.Get(1).(func(string, *busapi.UacInfo, int, time.Duration) *authenticate.UACClaims); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*authenticate.UACClaims)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, *busapi.UacInfo, int, time.Duration) error); ok {
+		r2 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RefreshJWT provides a mock function with given fields: _a0
+func (_m *JWTCryptoInterface) RefreshJWT(_a0 *authenticate.UACClaims) (string, error) {
+	ret := _m.Called(_a0)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(*authenticate.UACClaims) string); ok {
+		r0 = rf(_a0)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, *busapi.UacInfo, int) error); ok {
-		r1 = rf(_a0, _a1, _a2)
+	if rf, ok := ret.Get(1).(func(*authenticate.UACClaims) error); ok {
+		r1 = rf(_a0)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,62 @@
+package authenticate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Groups of characters respondents commonly mistake for each other. Where
+// exactly one character of a group is in a UAC alphabet, the others are
+// read as that character. Without an alphabet nothing is mapped.
+var confusableGroups = []string{
+	"0O",
+	"1IL",
+	"5S",
+	"2Z",
+	"8B",
+}
+
+const uacSeparators = "-‐‑‒–—_./\\"
+
+// Normalise converts what a respondent typed into the form BUS issued: upper
+// case, without spaces or separators, and with confusable characters mapped
+// into the alphabet
+func (uacValidator *UacValidator) Normalise(uac string) string {
+	confusables := confusableMapping(uacValidator.Alphabet)
+	var normalised strings.Builder
+	for _, character := range strings.ToUpper(uac) {
+		if unicode.IsSpace(character) || strings.ContainsRune(uacSeparators, character) {
+			continue
+		}
+		if canonical, found := confusables[character]; found {
+			character = canonical
+		}
+		normalised.WriteRune(character)
+	}
+	return normalised.String()
+}
+
+func confusableMapping(alphabet string) map[rune]rune {
+	mapping := map[rune]rune{}
+	for _, group := range confusableGroups {
+		var (
+			canonical  rune
+			inAlphabet int
+		)
+		for _, character := range group {
+			if strings.ContainsRune(alphabet, character) {
+				canonical = character
+				inAlphabet++
+			}
+		}
+		if inAlphabet != 1 {
+			continue
+		}
+		for _, character := range group {
+			if character != canonical {
+				mapping[character] = canonical
+			}
+		}
+	}
+	return mapping
+}
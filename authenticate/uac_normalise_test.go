@@ -0,0 +1,69 @@
+package authenticate_test
+
+import (
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UacValidator.Normalise", func() {
+	var uacValidators, _ = authenticate.NewUacValidators(authenticate.CHECKSUM_NONE, authenticate.CHECKSUM_NONE, uac16Alphabet)
+
+	DescribeTable("16 character UACs",
+		func(typed, expected string) {
+			Expect(uacValidators[authenticate.UAC16].Normalise(typed)).To(Equal(expected))
+		},
+		Entry("already normalised", "BCDF5678GHJK2345", "BCDF5678GHJK2345"),
+		Entry("lower case", "bcdf5678ghjk2345", "BCDF5678GHJK2345"),
+		Entry("mixed case", "bCdF5678GhJk2345", "BCDF5678GHJK2345"),
+		Entry("spaces", "bcdf 5678 ghjk 2345", "BCDF5678GHJK2345"),
+		Entry("leading and trailing whitespace", "  bcdf5678ghjk2345\t", "BCDF5678GHJK2345"),
+		Entry("dashes", "bcdf-5678-ghjk-2345", "BCDF5678GHJK2345"),
+		Entry("en dashes", "bcdf–5678–ghjk–2345", "BCDF5678GHJK2345"),
+		Entry("dots and slashes", "bcdf.5678/ghjk_2345", "BCDF5678GHJK2345"),
+		Entry("letter O for zero", "BCDF5678GHJK23O5", "BCDF5678GHJK2305"),
+		Entry("lower case o for zero", "bcdf5678ghjk23o5", "BCDF5678GHJK2305"),
+		Entry("letter I for one", "BCDF5678GHJKI345", "BCDF5678GHJK1345"),
+		Entry("letter L for one", "BCDF5678GHJKL345", "BCDF5678GHJK1345"),
+		Entry("lower case l for one", "bcdf5678ghjkl345", "BCDF5678GHJK1345"),
+		Entry("letter S for five", "BCDFS678GHJK2345", "BCDF5678GHJK2345"),
+		Entry("Z and 2 are both in the alphabet", "BCDF5678GHJKZ345", "BCDF5678GHJKZ345"),
+		Entry("B and 8 are both in the alphabet", "BCDF567BGHJK2345", "BCDF567BGHJK2345"),
+		Entry("everything at once", " bcdf-s678 ghjk-lo45 ", "BCDF5678GHJK1045"),
+		Entry("characters outside the alphabet are left for validation", "BCDF5678GHJK234!", "BCDF5678GHJK234!"),
+	)
+
+	DescribeTable("12 digit UACs",
+		func(typed, expected string) {
+			Expect(uacValidators[authenticate.UAC12].Normalise(typed)).To(Equal(expected))
+		},
+		Entry("already normalised", "123456789012", "123456789012"),
+		Entry("spaces", "1234 5678 9012", "123456789012"),
+		Entry("dashes", "1234-5678-9012", "123456789012"),
+		Entry("letter O for zero", "123456789O12", "123456789012"),
+		Entry("letters I and l for one", "I234567890l2", "123456789012"),
+		Entry("letter S for five", "1234S6789012", "123456789012"),
+		Entry("letter Z for two", "1Z3456789012", "123456789012"),
+		Entry("letter B for eight", "1234567B9012", "123456789012"),
+	)
+
+	DescribeTable("16 character UACs without an alphabet",
+		func(typed, expected string) {
+			uacValidators, _ := authenticate.NewUacValidators(authenticate.CHECKSUM_NONE, authenticate.CHECKSUM_NONE, "")
+			Expect(uacValidators[authenticate.UAC16].Normalise(typed)).To(Equal(expected))
+		},
+		Entry("still removes separators and upper cases", "bcdf-5678 ghjk-2345", "BCDF5678GHJK2345"),
+		Entry("keeps confusable characters as typed", "ilos-ilos-ilos-ilos", "ILOSILOSILOSILOS"),
+	)
+
+	DescribeTable("custom alphabets",
+		func(alphabet, typed, expected string) {
+			uacValidators, _ := authenticate.NewUacValidators(authenticate.CHECKSUM_NONE, authenticate.CHECKSUM_NONE, alphabet)
+			Expect(uacValidators[authenticate.UAC16].Normalise(typed)).To(Equal(expected))
+		},
+		Entry("maps towards letters when digits are excluded", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "0123", "O1Z3"),
+		Entry("leaves groups alone when no member is in the alphabet", "ABCD", "O1IL", "O1IL"),
+	)
+})
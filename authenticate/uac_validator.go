@@ -0,0 +1,98 @@
+package authenticate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	UAC12    = "uac"
+	UAC16    = "uac16"
+	UAC_BOTH = "both"
+
+	UAC12_ALPHABET = "0123456789"
+)
+
+var (
+	UAC_LENGTHS = map[string]int{
+		UAC12: 12,
+		UAC16: 16,
+	}
+	UAC_DESCRIPTIONS = map[string]map[string]string{
+		UAC12: {
+			"english": "12-digit",
+			"welsh":   "12 o nodau",
+		},
+		UAC16: {
+			"english": "16-character",
+			"welsh":   "16 o nodau",
+		},
+		UAC_BOTH: {
+			"english": "12-digit or 16-character",
+			"welsh":   "12 neu 16 o nodau",
+		},
+	}
+
+	InvalidUacLengthError     = errors.New("invalid UAC length")
+	InvalidUacCharactersError = errors.New("invalid UAC characters")
+	InvalidUacCheckDigitError = errors.New("invalid UAC check digit")
+)
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name UacValidatorInterface
+type UacValidatorInterface interface {
+	Normalise(string) string
+	Validate(string) error
+}
+
+// UacValidator rejects UACs that could not have been issued by BUS, so
+// typos can be reported without a round trip to BUS. Without an Alphabet
+// only the length is checked, as the characters BUS uses are not known.
+type UacValidator struct {
+	Length   int
+	Alphabet string
+	Checksum ChecksumInterface
+}
+
+func (uacValidator *UacValidator) Validate(uac string) error {
+	uac = strings.ToUpper(uac)
+	if len(uac) != uacValidator.Length {
+		return InvalidUacLengthError
+	}
+	if uacValidator.Alphabet == "" {
+		return nil
+	}
+	for _, character := range uac {
+		if !strings.ContainsRune(uacValidator.Alphabet, character) {
+			return InvalidUacCharactersError
+		}
+	}
+	if !uacValidator.Checksum.Valid(uac, uacValidator.Alphabet) {
+		return InvalidUacCheckDigitError
+	}
+	return nil
+}
+
+// NewUacValidators builds a validator for each UAC kind, keyed by kind.
+// 16-character UACs are only checked against an alphabet, and only have
+// confusable characters mapped, when uac16Alphabet is set, so it should only
+// be set to the alphabet BUS issues codes from. A 16-character checksum
+// needs the alphabet too.
+func NewUacValidators(uac12Checksum, uac16Checksum, uac16Alphabet string) (map[string]UacValidatorInterface, error) {
+	checksum12, err := ChecksumFromName(uac12Checksum)
+	if err != nil {
+		return nil, fmt.Errorf("12-digit UACs: %w", err)
+	}
+	checksum16, err := ChecksumFromName(uac16Checksum)
+	if err != nil {
+		return nil, fmt.Errorf("16-character UACs: %w", err)
+	}
+	if _, noChecksum := checksum16.(NoChecksum); !noChecksum && uac16Alphabet == "" {
+		return nil, fmt.Errorf("16-character UACs: the %s checksum needs UAC16_ALPHABET", strings.ToLower(uac16Checksum))
+	}
+	return map[string]UacValidatorInterface{
+		UAC12: &UacValidator{Length: UAC_LENGTHS[UAC12], Alphabet: UAC12_ALPHABET, Checksum: checksum12},
+		UAC16: &UacValidator{Length: UAC_LENGTHS[UAC16], Alphabet: strings.ToUpper(uac16Alphabet), Checksum: checksum16},
+	}, nil
+}
@@ -0,0 +1,67 @@
+package authenticate_test
+
+import (
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// uac16Alphabet is an example alphabet without I, L, O or S
+const uac16Alphabet = "0123456789ABCDEFGHJKMNPQRTUVWXYZ"
+
+var _ = Describe("UacValidator", func() {
+	DescribeTable("Validate",
+		func(kind, checksum, uac string, expected error) {
+			uacValidators, err := authenticate.NewUacValidators(checksum, checksum, uac16Alphabet)
+			Expect(err).To(BeNil())
+			if expected == nil {
+				Expect(uacValidators[kind].Validate(uac)).To(Succeed())
+			} else {
+				Expect(uacValidators[kind].Validate(uac)).To(Equal(expected))
+			}
+		},
+		Entry("12 digit without a checksum", authenticate.UAC12, authenticate.CHECKSUM_NONE, "123456789012", nil),
+		Entry("12 digit with letters", authenticate.UAC12, authenticate.CHECKSUM_NONE, "12345678901A", authenticate.InvalidUacCharactersError),
+		Entry("12 digit too short", authenticate.UAC12, authenticate.CHECKSUM_NONE, "12345678901", authenticate.InvalidUacLengthError),
+		Entry("12 digit with a valid luhn check digit", authenticate.UAC12, authenticate.CHECKSUM_LUHN, "123456789015", nil),
+		Entry("12 digit with an invalid luhn check digit", authenticate.UAC12, authenticate.CHECKSUM_LUHN, "123456789016", authenticate.InvalidUacCheckDigitError),
+		Entry("12 digit with a valid iso7064 check digit", authenticate.UAC12, authenticate.CHECKSUM_ISO7064, "123456789014", nil),
+		Entry("12 digit with an invalid iso7064 check digit", authenticate.UAC12, authenticate.CHECKSUM_ISO7064, "123456789015", authenticate.InvalidUacCheckDigitError),
+		Entry("16 character without a checksum", authenticate.UAC16, authenticate.CHECKSUM_NONE, "BCDF5678GHJK2345", nil),
+		Entry("16 character in lower case", authenticate.UAC16, authenticate.CHECKSUM_NONE, "bcdf5678ghjk2345", nil),
+		Entry("16 character with characters outside the alphabet", authenticate.UAC16, authenticate.CHECKSUM_NONE, "BCDF5678GHJK234O", authenticate.InvalidUacCharactersError),
+		Entry("16 character with punctuation", authenticate.UAC16, authenticate.CHECKSUM_NONE, "BCDF5678GHJK234!", authenticate.InvalidUacCharactersError),
+		Entry("16 character with a valid luhn check character", authenticate.UAC16, authenticate.CHECKSUM_LUHN, "BCDF5678GHJK234P", nil),
+		Entry("16 character with an invalid luhn check character", authenticate.UAC16, authenticate.CHECKSUM_LUHN, "BCDF5678GHJK234Q", authenticate.InvalidUacCheckDigitError),
+		Entry("16 character with a valid iso7064 check character", authenticate.UAC16, authenticate.CHECKSUM_ISO7064, "BCDF5678GHJK2344", nil),
+		Entry("16 character with an invalid iso7064 check character", authenticate.UAC16, authenticate.CHECKSUM_ISO7064, "BCDF5678GHJK2345", authenticate.InvalidUacCheckDigitError),
+	)
+
+	Describe("NewUacValidators", func() {
+		It("uses a custom 16 character alphabet", func() {
+			uacValidators, err := authenticate.NewUacValidators(authenticate.CHECKSUM_NONE, authenticate.CHECKSUM_NONE, "abcd")
+			Expect(err).To(BeNil())
+			Expect(uacValidators[authenticate.UAC16].Validate("ABCDABCDABCDABCD")).To(Succeed())
+			Expect(uacValidators[authenticate.UAC16].Validate("ABCDABCDABCDABCE")).To(Equal(authenticate.InvalidUacCharactersError))
+		})
+
+		It("only checks the length of 16 character UACs without an alphabet", func() {
+			uacValidators, err := authenticate.NewUacValidators(authenticate.CHECKSUM_NONE, authenticate.CHECKSUM_NONE, "")
+			Expect(err).To(BeNil())
+			Expect(uacValidators[authenticate.UAC16].Validate("ILOSILOSILOSILOS")).To(Succeed())
+			Expect(uacValidators[authenticate.UAC16].Validate("ILOSILOSILOSILO")).To(Equal(authenticate.InvalidUacLengthError))
+		})
+
+		It("needs an alphabet for a 16 character checksum", func() {
+			_, err := authenticate.NewUacValidators(authenticate.CHECKSUM_NONE, authenticate.CHECKSUM_LUHN, "")
+			Expect(err).To(MatchError("16-character UACs: the luhn checksum needs UAC16_ALPHABET"))
+		})
+
+		It("errors for an unknown checksum algorithm", func() {
+			_, err := authenticate.NewUacValidators("verhoeff", authenticate.CHECKSUM_NONE, "")
+			Expect(err).To(MatchError(`12-digit UACs: unknown UAC checksum algorithm "verhoeff"`))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package blaise_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestBlaise(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Blaise Suite")
+}
@@ -0,0 +1,110 @@
+package blaise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+)
+
+// UPSTREAM names CATI in the errors Launch returns
+const UPSTREAM = "CATI"
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name LauncherInterface
+type LauncherInterface interface {
+	Launch(context.Context, string, string, LaunchBlaise) (*LaunchResponse, error)
+}
+
+// Launcher opens cases in CATI, giving up on a launch after Timeout, if set.
+type Launcher struct {
+	Client  *http.Client
+	Timeout time.Duration
+}
+
+// LaunchResponse is the page CATI opened the case with
+type LaunchResponse struct {
+	ContentType string
+	Body        []byte
+}
+
+// Launch posts the case to the instrument's default.aspx. Failures are
+// returned as one of upstream.TimeoutError, UnavailableError, NotFoundError,
+// RedirectError or MalformedResponseError. If the caller's context ends
+// first, its error is returned instead, as that is no fault of CATI.
+func (launcher *Launcher) Launch(ctx context.Context, catiUrl, instrumentName string, payload LaunchBlaise) (*LaunchResponse, error) {
+	requestCtx, cancel := utils.WithTimeout(ctx, launcher.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(requestCtx, "POST",
+		fmt.Sprintf("%s/%s/default.aspx", catiUrl, instrumentName),
+		strings.NewReader(payload.Form().Encode()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := launcher.client().Do(req)
+	if err != nil {
+		return nil, launchError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil || isTimeout(err) {
+			return nil, launchError(ctx, err)
+		}
+		return nil, &upstream.MalformedResponseError{Upstream: UPSTREAM, Response: upstream.NewResponse(resp.StatusCode, nil), Err: err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return &LaunchResponse{ContentType: resp.Header.Get("Content-Type"), Body: body}, nil
+	case resp.StatusCode >= http.StatusMultipleChoices && resp.StatusCode < http.StatusBadRequest:
+		return nil, &upstream.RedirectError{Upstream: UPSTREAM, Response: upstream.NewResponse(resp.StatusCode, body), Location: resp.Header.Get("Location")}
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &upstream.NotFoundError{Upstream: UPSTREAM, Response: upstream.NewResponse(resp.StatusCode, body)}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, &upstream.UnavailableError{Upstream: UPSTREAM, Response: upstream.NewResponse(resp.StatusCode, body)}
+	default:
+		return nil, &upstream.MalformedResponseError{Upstream: UPSTREAM, Response: upstream.NewResponse(resp.StatusCode, body)}
+	}
+}
+
+// client is the configured client, made not to follow redirects
+func (launcher *Launcher) client() *http.Client {
+	client := &http.Client{}
+	if launcher.Client != nil {
+		*client = *launcher.Client
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client
+}
+
+// launchError classifies an error talking to CATI, where ctx is the caller's
+// context rather than the one bounded by the launcher's timeout
+func launchError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if isTimeout(err) {
+		return &upstream.TimeoutError{Upstream: UPSTREAM, Err: err}
+	}
+	return &upstream.UnavailableError{Upstream: UPSTREAM, Err: err}
+}
+
+func isTimeout(err error) bool {
+	var netError net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout())
+}
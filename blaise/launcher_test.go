@@ -0,0 +1,138 @@
+package blaise_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/blaise"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Launcher", func() {
+	var (
+		launcher *blaise.Launcher
+		server   *httptest.Server
+		handler  http.HandlerFunc
+		posted   *http.Request
+		form     string
+	)
+
+	BeforeEach(func() {
+		posted = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			posted, form = r, string(body)
+			handler(w, r)
+		}))
+		launcher = &blaise.Launcher{Client: server.Client()}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	launch := func(ctx context.Context) (*blaise.LaunchResponse, error) {
+		return launcher.Launch(ctx, server.URL, "dst2106a", blaise.CasePayload("100001", true))
+	}
+
+	It("posts the case to the instrument and returns the page", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><body>case</body></html>"))
+		}
+
+		launchResponse, err := launch(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(launchResponse).To(Equal(&blaise.LaunchResponse{
+			ContentType: "text/html; charset=utf-8",
+			Body:        []byte("<html><body>case</body></html>"),
+		}))
+		Expect(posted.Method).To(Equal("POST"))
+		Expect(posted.URL.Path).To(Equal("/dst2106a/default.aspx"))
+		Expect(posted.Header.Get("Content-Type")).To(Equal("application/x-www-form-urlencoded"))
+		Expect(form).To(Equal("KeyValue=100001&Language=WLS&Mode=CAWI"))
+	})
+
+	It("does not follow redirects", func() {
+		launcher.Client.CheckRedirect = nil
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}
+
+		_, err := launch(context.Background())
+		var redirectError *upstream.RedirectError
+		Expect(errors.As(err, &redirectError)).To(BeTrue())
+		Expect(redirectError.StatusCode).To(Equal(http.StatusFound))
+		Expect(redirectError.Location).To(Equal("/login"))
+		Expect(posted.URL.Path).To(Equal("/dst2106a/default.aspx"))
+		Expect(launcher.Client.CheckRedirect).To(BeNil())
+	})
+
+	It("gives up when CATI does not respond in time", func() {
+		launcher.Timeout = 10 * time.Millisecond
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}
+
+		_, err := launch(context.Background())
+		var timeoutError *upstream.TimeoutError
+		Expect(errors.As(err, &timeoutError)).To(BeTrue())
+	})
+
+	It("returns the caller's error when they go away first", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			<-r.Context().Done()
+		}
+
+		_, err := launch(ctx)
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("cannot reach CATI once it has gone", func() {
+		server.Close()
+
+		_, err := launch(context.Background())
+		var unavailableError *upstream.UnavailableError
+		Expect(errors.As(err, &unavailableError)).To(BeTrue())
+		Expect(unavailableError.StatusCode).To(BeZero())
+	})
+
+	respondWith := func(status int, check func(error)) func() {
+		return func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				w.Write([]byte(strings.Repeat("x", upstream.MAX_ERROR_BODY_LENGTH+1)))
+			}
+			_, err := launch(context.Background())
+			check(err)
+		}
+	}
+
+	It("classifies server errors as CATI being unavailable", respondWith(http.StatusServiceUnavailable, func(err error) {
+		var unavailableError *upstream.UnavailableError
+		Expect(errors.As(err, &unavailableError)).To(BeTrue())
+		Expect(unavailableError.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(unavailableError.Body).To(HaveLen(upstream.MAX_ERROR_BODY_LENGTH + 3))
+	}))
+
+	It("classifies a 404 as the instrument not being found", respondWith(http.StatusNotFound, func(err error) {
+		var notFoundError *upstream.NotFoundError
+		Expect(errors.As(err, &notFoundError)).To(BeTrue())
+	}))
+
+	It("classifies other statuses as unexpected", respondWith(http.StatusBadRequest, func(err error) {
+		var malformedError *upstream.MalformedResponseError
+		Expect(errors.As(err, &malformedError)).To(BeTrue())
+		Expect(malformedError.Error()).To(Equal("unexpected response from CATI (status 400)"))
+	}))
+})
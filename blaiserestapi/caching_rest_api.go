@@ -0,0 +1,187 @@
+package blaiserestapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+)
+
+// SETTINGS_VERSION_KEY_PREFIX keys the version of each instrument's settings
+// in the shared store, which InvalidateInstrumentSettings moves on
+const SETTINGS_VERSION_KEY_PREFIX = "instrument_settings_version"
+
+// CachingBlaiseRestApi caches instrument settings from another
+// BlaiseRestApiInterface for TTL, as they only change when an instrument is
+// deployed. Instruments that are not installed are remembered for the shorter
+// NotFoundTTL so that a newly deployed instrument is picked up quickly.
+// Concurrent misses for the same instrument share a single request.
+//
+// With Versions, settings cached on any instance are dropped once the
+// instrument is invalidated in that shared store. Each instrument's version is
+// read again at most every VersionPollInterval, so other instances see an
+// invalidation within that interval. If the store cannot be read, cached
+// settings are used until they expire.
+type CachingBlaiseRestApi struct {
+	BlaiseRestApi       BlaiseRestApiInterface
+	TTL                 time.Duration
+	NotFoundTTL         time.Duration
+	Versions            kvstore.StoreInterface
+	VersionPollInterval time.Duration
+
+	mutex    sync.Mutex
+	entries  map[string]cachedSettings
+	inFlight map[string]*settingsCall
+	versions map[string]polledVersion
+}
+
+type cachedSettings struct {
+	settings  InstrumentSettings
+	err       error
+	expiresAt time.Time
+	version   string
+}
+
+type polledVersion struct {
+	version string
+	known   bool
+	readAt  time.Time
+}
+
+type settingsCall struct {
+	done     chan struct{}
+	settings InstrumentSettings
+	err      error
+	version  string
+}
+
+func NewCachingBlaiseRestApi(blaiseRestApi BlaiseRestApiInterface, ttl, notFoundTTL time.Duration,
+	versions kvstore.StoreInterface, versionPollInterval time.Duration) *CachingBlaiseRestApi {
+	return &CachingBlaiseRestApi{
+		BlaiseRestApi:       blaiseRestApi,
+		TTL:                 ttl,
+		NotFoundTTL:         notFoundTTL,
+		Versions:            versions,
+		VersionPollInterval: versionPollInterval,
+		entries:             map[string]cachedSettings{},
+		inFlight:            map[string]*settingsCall{},
+		versions:            map[string]polledVersion{},
+	}
+}
+
+func (cachingApi *CachingBlaiseRestApi) GetInstrumentSettings(ctx context.Context, instrumentName string) (InstrumentSettings, error) {
+	key := strings.ToLower(instrumentName)
+	version, versionKnown := cachingApi.version(key)
+
+	cachingApi.mutex.Lock()
+	if entry, found := cachingApi.entries[key]; found && time.Now().Before(entry.expiresAt) &&
+		(!versionKnown || entry.version == version) {
+		cachingApi.mutex.Unlock()
+		return entry.settings, entry.err
+	}
+	call, found := cachingApi.inFlight[key]
+	if !found || (versionKnown && call.version != version) {
+		call = &settingsCall{done: make(chan struct{}), version: version}
+		cachingApi.inFlight[key] = call
+		go cachingApi.fetch(ctx, key, instrumentName, call)
+	}
+	cachingApi.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.settings, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch gets the settings for everyone waiting on them. It is detached from
+// the context of the caller that started it, which may give up before the
+// others do; the wrapped API's own timeout still applies.
+func (cachingApi *CachingBlaiseRestApi) fetch(ctx context.Context, key, instrumentName string, call *settingsCall) {
+	call.settings, call.err = cachingApi.BlaiseRestApi.GetInstrumentSettings(detachedContext{ctx}, instrumentName)
+
+	cachingApi.mutex.Lock()
+	// Settings fetched before the instrument was invalidated are not cached
+	if cachingApi.inFlight[key] == call {
+		delete(cachingApi.inFlight, key)
+		switch {
+		case call.err == nil && cachingApi.TTL > 0:
+			cachingApi.entries[key] = cachedSettings{settings: call.settings,
+				expiresAt: time.Now().Add(cachingApi.TTL), version: call.version}
+		case call.err == InstrumentNotFoundError && cachingApi.NotFoundTTL > 0:
+			cachingApi.entries[key] = cachedSettings{err: call.err,
+				expiresAt: time.Now().Add(cachingApi.NotFoundTTL), version: call.version}
+		}
+	}
+	cachingApi.mutex.Unlock()
+
+	close(call.done)
+}
+
+// Invalidate forgets the cached settings for an instrument, for when it has
+// been redeployed, here and, with Versions, on every other instance
+func (cachingApi *CachingBlaiseRestApi) Invalidate(instrumentName string) error {
+	cachingApi.mutex.Lock()
+	delete(cachingApi.entries, strings.ToLower(instrumentName))
+	delete(cachingApi.inFlight, strings.ToLower(instrumentName))
+	delete(cachingApi.versions, strings.ToLower(instrumentName))
+	cachingApi.mutex.Unlock()
+
+	if cachingApi.Versions == nil {
+		return nil
+	}
+	return InvalidateInstrumentSettings(cachingApi.Versions, instrumentName)
+}
+
+// InvalidateInstrumentSettings makes every instance caching settings with
+// versions in store fetch an instrument's settings again
+func InvalidateInstrumentSettings(store kvstore.StoreInterface, instrumentName string) error {
+	_, err := store.Incr(settingsVersionKey(strings.ToLower(instrumentName)), 0)
+	return err
+}
+
+// version is the shared version of an instrument's settings, if it can be
+// read, as last read within VersionPollInterval
+func (cachingApi *CachingBlaiseRestApi) version(key string) (string, bool) {
+	if cachingApi.Versions == nil {
+		return "", false
+	}
+	cachingApi.mutex.Lock()
+	polled, found := cachingApi.versions[key]
+	cachingApi.mutex.Unlock()
+	if found && time.Since(polled.readAt) < cachingApi.VersionPollInterval {
+		return polled.version, polled.known
+	}
+
+	version, err := cachingApi.Versions.Get(settingsVersionKey(key))
+	polled = polledVersion{version: version, known: err == nil, readAt: time.Now()}
+	cachingApi.mutex.Lock()
+	cachingApi.versions[key] = polled
+	cachingApi.mutex.Unlock()
+	return polled.version, polled.known
+}
+
+func settingsVersionKey(key string) string {
+	return fmt.Sprintf("%s:%s", SETTINGS_VERSION_KEY_PREFIX, key)
+}
+
+func (cachingApi *CachingBlaiseRestApi) GetCaseStatus(ctx context.Context, instrumentName, caseID string) (CaseStatus, error) {
+	return cachingApi.BlaiseRestApi.GetCaseStatus(ctx, instrumentName, caseID)
+}
+
+// detachedContext keeps a context's values but not its deadline or
+// cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (ctx detachedContext) Value(key interface{}) interface{} {
+	return ctx.parent.Value(key)
+}
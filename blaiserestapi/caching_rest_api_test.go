@@ -0,0 +1,191 @@
+package blaiserestapi_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
+	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	kvMocks "github.com/ONSdigital/blaise-cawi-portal/kvstore/mocks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Caching Blaise rest api", func() {
+	var (
+		mockRestApi *mocks.BlaiseRestApiInterface
+		cachingApi  *blaiserestapi.CachingBlaiseRestApi
+		settings    = blaiserestapi.InstrumentSettings{{Type: "StrictInterviewing", SessionTimeout: 20}}
+		ctx         = context.Background()
+	)
+
+	BeforeEach(func() {
+		mockRestApi = &mocks.BlaiseRestApiInterface{}
+		cachingApi = blaiserestapi.NewCachingBlaiseRestApi(mockRestApi, time.Minute, 30*time.Millisecond, nil, 0)
+	})
+
+	It("caches instrument settings", func() {
+		mockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Return(settings, nil)
+
+		Expect(cachingApi.GetInstrumentSettings(ctx, "dst2101a")).To(Equal(settings))
+		Expect(cachingApi.GetInstrumentSettings(ctx, "DST2101A")).To(Equal(settings))
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+	})
+
+	It("fetches the settings again once invalidated", func() {
+		mockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Return(settings, nil)
+
+		cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+		Expect(cachingApi.Invalidate("dst2101a")).To(Succeed())
+		cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 2)
+	})
+
+	Context("with versions shared between instances", func() {
+		var (
+			store            *kvstore.MemoryStore
+			otherMockRestApi *mocks.BlaiseRestApiInterface
+			otherInstanceApi *blaiserestapi.CachingBlaiseRestApi
+		)
+
+		BeforeEach(func() {
+			store = kvstore.NewMemoryStore()
+			cachingApi.Versions = store
+			cachingApi.VersionPollInterval = 20 * time.Millisecond
+			otherMockRestApi = &mocks.BlaiseRestApiInterface{}
+			otherInstanceApi = blaiserestapi.NewCachingBlaiseRestApi(otherMockRestApi, time.Minute, time.Minute, store, 20*time.Millisecond)
+			mockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Return(settings, nil)
+			otherMockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Return(settings, nil)
+		})
+
+		It("still caches instrument settings", func() {
+			cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+		})
+
+		It("fetches the settings again on every instance once invalidated on one", func() {
+			cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			otherInstanceApi.GetInstrumentSettings(ctx, "dst2101a")
+
+			Expect(cachingApi.Invalidate("DST2101A")).To(Succeed())
+
+			cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 2)
+
+			otherInstanceApi.GetInstrumentSettings(ctx, "dst2101a")
+			otherMockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+			time.Sleep(30 * time.Millisecond)
+			otherInstanceApi.GetInstrumentSettings(ctx, "dst2101a")
+			otherInstanceApi.GetInstrumentSettings(ctx, "dst2101a")
+			otherMockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 2)
+		})
+
+		It("fetches the settings again once invalidated from outside the portal", func() {
+			otherInstanceApi.GetInstrumentSettings(ctx, "dst2101a")
+			Expect(blaiserestapi.InvalidateInstrumentSettings(store, "dst2101a")).To(Succeed())
+			time.Sleep(30 * time.Millisecond)
+			otherInstanceApi.GetInstrumentSettings(ctx, "dst2101a")
+			otherMockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 2)
+		})
+
+		It("reads the version only once per poll interval", func() {
+			countingStore := &kvMocks.StoreInterface{}
+			countingStore.On("Get", mock.Anything).Return("1", nil)
+			cachingApi.Versions = countingStore
+
+			for i := 0; i < 5; i++ {
+				cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			}
+			countingStore.AssertNumberOfCalls(GinkgoT(), "Get", 1)
+
+			time.Sleep(30 * time.Millisecond)
+			cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			countingStore.AssertNumberOfCalls(GinkgoT(), "Get", 2)
+			mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+		})
+
+		It("keeps using cached settings when the versions cannot be read", func() {
+			failingStore := &kvMocks.StoreInterface{}
+			failingStore.On("Get", mock.Anything).Return("", errors.New("connection refused"))
+			cachingApi.Versions = failingStore
+
+			cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+			mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+		})
+	})
+
+	It("remembers instruments that are not installed for a short time", func() {
+		mockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Return(nil, blaiserestapi.InstrumentNotFoundError)
+
+		_, err := cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+		Expect(err).To(Equal(blaiserestapi.InstrumentNotFoundError))
+		_, err = cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+		Expect(err).To(Equal(blaiserestapi.InstrumentNotFoundError))
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+
+		time.Sleep(40 * time.Millisecond)
+		cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 2)
+	})
+
+	It("does not cache other errors", func() {
+		mockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Return(nil, errors.New("connection refused"))
+
+		cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+		cachingApi.GetInstrumentSettings(ctx, "dst2101a")
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 2)
+	})
+
+	It("shares one request between concurrent misses", func() {
+		release := make(chan struct{})
+		mockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Run(func(mock.Arguments) {
+			<-release
+		}).Return(settings, nil)
+
+		var waitGroup sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			waitGroup.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer waitGroup.Done()
+				Expect(cachingApi.GetInstrumentSettings(ctx, "dst2101a")).To(Equal(settings))
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		waitGroup.Wait()
+
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+	})
+
+	It("lets a caller give up without cancelling the request for everyone else", func() {
+		release := make(chan struct{})
+		mockRestApi.On("GetInstrumentSettings", mock.Anything, "dst2101a").Run(func(args mock.Arguments) {
+			<-release
+			Expect(args.Get(0).(context.Context).Err()).To(BeNil())
+		}).Return(settings, nil)
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := cachingApi.GetInstrumentSettings(cancelledCtx, "dst2101a")
+		Expect(err).To(Equal(context.Canceled))
+
+		close(release)
+		Expect(cachingApi.GetInstrumentSettings(ctx, "dst2101a")).To(Equal(settings))
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetInstrumentSettings", 1)
+	})
+
+	It("passes case status lookups straight through", func() {
+		mockRestApi.On("GetCaseStatus", mock.Anything, "dst2101a", "100001").Return(blaiserestapi.CaseStatus{Outcome: 110}, nil)
+
+		Expect(cachingApi.GetCaseStatus(ctx, "dst2101a", "100001")).To(Equal(blaiserestapi.CaseStatus{Outcome: 110}))
+		Expect(cachingApi.GetCaseStatus(ctx, "dst2101a", "100001")).To(Equal(blaiserestapi.CaseStatus{Outcome: 110}))
+		mockRestApi.AssertNumberOfCalls(GinkgoT(), "GetCaseStatus", 2)
+	})
+})
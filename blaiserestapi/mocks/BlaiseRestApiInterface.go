@@ -3,7 +3,10 @@
 package mocks
 
 import (
+	context "context"
+
 	blaiserestapi "github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -12,13 +15,34 @@ type BlaiseRestApiInterface struct {
 	mock.Mock
 }
 
-// GetInstrumentSettings provides a mock function with given fields: _a0
-func (_m *BlaiseRestApiInterface) GetInstrumentSettings(_a0 string) (blaiserestapi.InstrumentSettings, error) {
-	ret := _m.Called(_a0)
+// GetCaseStatus provides a mock function with given fields: _a0, _a1, _a2
+func (_m *BlaiseRestApiInterface) GetCaseStatus(_a0 context.Context, _a1 string, _a2 string) (blaiserestapi.CaseStatus, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 blaiserestapi.CaseStatus
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) blaiserestapi.CaseStatus); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Get(0).(blaiserestapi.CaseStatus)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetInstrumentSettings provides a mock function with given fields: _a0, _a1
+func (_m *BlaiseRestApiInterface) GetInstrumentSettings(_a0 context.Context, _a1 string) (blaiserestapi.InstrumentSettings, error) {
+	ret := _m.Called(_a0, _a1)
 
 	var r0 blaiserestapi.InstrumentSettings
-	if rf, ok := ret.Get(0).(func(string) blaiserestapi.InstrumentSettings); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, string) blaiserestapi.InstrumentSettings); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(blaiserestapi.InstrumentSettings)
@@ -26,8 +50,8 @@ func (_m *BlaiseRestApiInterface) GetInstrumentSettings(_a0 string) (blaiseresta
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -1,17 +1,25 @@
 package blaiserestapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/credentials"
+	"github.com/ONSdigital/blaise-cawi-portal/serverpark"
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+	log "github.com/sirupsen/logrus"
 )
 
 //Generate mocks by running "go generate ./..."
 //go:generate mockery --name BlaiseRestApiInterface
 type BlaiseRestApiInterface interface {
-	GetInstrumentSettings(string) (InstrumentSettings, error)
+	GetInstrumentSettings(context.Context, string) (InstrumentSettings, error)
+	GetCaseStatus(context.Context, string, string) (CaseStatus, error)
 }
 
 type InstrumentSettingsType struct {
@@ -26,7 +34,25 @@ type InstrumentSettingsType struct {
 
 type InstrumentSettings []InstrumentSettingsType
 
-var InstrumentNotFoundError = fmt.Errorf("instrument not found")
+// Blaise case outcome codes
+const (
+	OUTCOME_COMPLETED = 110
+	OUTCOME_PARTIAL   = 210
+)
+
+type CaseStatus struct {
+	PrimaryKey string `json:"primaryKey"`
+	Outcome    int    `json:"outcome"`
+}
+
+func (caseStatus CaseStatus) Completed() bool {
+	return caseStatus.Outcome == OUTCOME_COMPLETED
+}
+
+var (
+	InstrumentNotFoundError = fmt.Errorf("instrument not found")
+	CaseNotFoundError       = fmt.Errorf("case not found")
+)
 
 func (instrumentSettings InstrumentSettings) StrictInterviewing() InstrumentSettingsType {
 	for _, instrumentSettingType := range instrumentSettings {
@@ -38,19 +64,31 @@ func (instrumentSettings InstrumentSettings) StrictInterviewing() InstrumentSett
 	return InstrumentSettingsType{}
 }
 
+// BlaiseRestApi calls the Blaise REST API, giving up on a call after Timeout,
+// if set. Instruments are looked up on the server park Serverparks routes them to,
+// or on Serverpark without one. Requests carry Credentials, if set.
 type BlaiseRestApi struct {
-	BaseUrl    string
-	Serverpark string
-	Client     *http.Client
+	BaseUrl     string
+	Serverpark  string
+	Serverparks serverpark.ResolverInterface
+	Credentials credentials.CredentialsInterface
+	Client      *http.Client
+	Timeout     time.Duration
 }
 
-func (blaiseRestApi *BlaiseRestApi) GetInstrumentSettings(instrumentName string) (InstrumentSettings, error) {
-	req, err := http.NewRequest("GET", blaiseRestApi.instrumentSettingsUrl(instrumentName), nil)
+func (blaiseRestApi *BlaiseRestApi) GetInstrumentSettings(ctx context.Context, instrumentName string) (InstrumentSettings, error) {
+	ctx, cancel := utils.WithTimeout(ctx, blaiseRestApi.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", blaiseRestApi.instrumentSettingsUrl(instrumentName), nil)
 	if err != nil {
 		log.Error("Failed to make new request to blaise rest api")
 		return nil, err
 	}
 	req.Header.Add("Accept", "application/json")
+	if err := blaiseRestApi.authorise(req); err != nil {
+		log.Error("Failed to authorise request to blaise rest api")
+		return nil, err
+	}
 	resp, err := blaiseRestApi.Client.Do(req)
 	if err != nil {
 		log.Error("Failed to get instrument settings")
@@ -75,11 +113,75 @@ func (blaiseRestApi *BlaiseRestApi) GetInstrumentSettings(instrumentName string)
 	return instrumentSettings, nil
 }
 
+func (blaiseRestApi *BlaiseRestApi) GetCaseStatus(ctx context.Context, instrumentName, caseID string) (CaseStatus, error) {
+	ctx, cancel := utils.WithTimeout(ctx, blaiseRestApi.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", blaiseRestApi.caseStatusUrl(instrumentName, caseID), nil)
+	if err != nil {
+		log.Error("Failed to make new request to blaise rest api")
+		return CaseStatus{}, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if err := blaiseRestApi.authorise(req); err != nil {
+		log.Error("Failed to authorise request to blaise rest api")
+		return CaseStatus{}, err
+	}
+	resp, err := blaiseRestApi.Client.Do(req)
+	if err != nil {
+		log.Error("Failed to get case status")
+		return CaseStatus{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		log.Error(fmt.Sprintf("Case %s not found in questionnaire %s", caseID, instrumentName))
+		return CaseStatus{}, CaseNotFoundError
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Error(fmt.Sprintf("Error reading response body of %s", resp.Body))
+		return CaseStatus{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return CaseStatus{}, fmt.Errorf("unexpected status code %d getting case status", resp.StatusCode)
+	}
+	var caseStatus CaseStatus
+	err = json.Unmarshal(body, &caseStatus)
+	if err != nil {
+		log.Error(fmt.Sprintf("Could not unmarshall %s", body))
+		return CaseStatus{}, err
+	}
+	return caseStatus, nil
+}
+
+func (blaiseRestApi *BlaiseRestApi) authorise(req *http.Request) error {
+	if blaiseRestApi.Credentials == nil {
+		return nil
+	}
+	return blaiseRestApi.Credentials.Authorise(req)
+}
+
+func (blaiseRestApi *BlaiseRestApi) serverpark(instrumentName string) string {
+	if blaiseRestApi.Serverparks == nil {
+		return blaiseRestApi.Serverpark
+	}
+	return blaiseRestApi.Serverparks.Resolve(instrumentName).Serverpark
+}
+
 func (blaiseRestApi *BlaiseRestApi) instrumentSettingsUrl(instrumentName string) string {
 	return fmt.Sprintf(
 		"%s/api/v2/serverparks/%s/questionnaires/%s/settings",
 		blaiseRestApi.BaseUrl,
-		blaiseRestApi.Serverpark,
+		blaiseRestApi.serverpark(instrumentName),
+		instrumentName,
+	)
+}
+
+func (blaiseRestApi *BlaiseRestApi) caseStatusUrl(instrumentName, caseID string) string {
+	return fmt.Sprintf(
+		"%s/api/v2/serverparks/%s/questionnaires/%s/cases/%s/status",
+		blaiseRestApi.BaseUrl,
+		blaiseRestApi.serverpark(instrumentName),
 		instrumentName,
+		url.PathEscape(caseID),
 	)
 }
@@ -0,0 +1,105 @@
+package blaiserestapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
+	"github.com/ONSdigital/blaise-cawi-portal/credentials"
+	"golang.org/x/oauth2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token() (*oauth2.Token, error) {
+	return nil, errors.New("metadata server unavailable")
+}
+
+var _ = Describe("Blaise rest api credentials", func() {
+	var (
+		restApiServer       *httptest.Server
+		authorizationHeader string
+		requests            int
+		blaiseRestApi       *blaiserestapi.BlaiseRestApi
+	)
+
+	BeforeEach(func() {
+		requests = 0
+		restApiServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			authorizationHeader = r.Header.Get("Authorization")
+			if authorizationHeader != "Bearer let-me-in" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"type": "StrictInterviewing", "sessionTimeout": 15}]`))
+		}))
+		blaiseRestApi = &blaiserestapi.BlaiseRestApi{
+			BaseUrl:    restApiServer.URL,
+			Serverpark: "gusty",
+			Client:     restApiServer.Client(),
+		}
+	})
+
+	AfterEach(func() {
+		restApiServer.Close()
+	})
+
+	Context("with no credentials", func() {
+		BeforeEach(func() {
+			blaiseRestApi.Credentials = credentials.NoCredentials{}
+		})
+
+		It("sends no Authorization header", func() {
+			_, err := blaiseRestApi.GetInstrumentSettings(context.Background(), "dst2106a")
+			Expect(err).To(HaveOccurred())
+			Expect(requests).To(Equal(1))
+			Expect(authorizationHeader).To(BeEmpty())
+		})
+	})
+
+	Context("with a bearer token", func() {
+		BeforeEach(func() {
+			blaiseRestApi.Credentials = &credentials.BearerToken{Token: "let-me-in"}
+		})
+
+		It("sends the token", func() {
+			instrumentSettings, err := blaiseRestApi.GetInstrumentSettings(context.Background(), "dst2106a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instrumentSettings.StrictInterviewing().SessionTimeout).To(Equal(15))
+			Expect(authorizationHeader).To(Equal("Bearer let-me-in"))
+		})
+	})
+
+	Context("with an ID token", func() {
+		BeforeEach(func() {
+			blaiseRestApi.Credentials = &credentials.IDToken{
+				TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "let-me-in", TokenType: "Bearer"}),
+			}
+		})
+
+		It("sends the token", func() {
+			_, err := blaiseRestApi.GetInstrumentSettings(context.Background(), "dst2106a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authorizationHeader).To(Equal("Bearer let-me-in"))
+		})
+
+		Context("when no token can be fetched", func() {
+			BeforeEach(func() {
+				blaiseRestApi.Credentials = &credentials.IDToken{TokenSource: failingTokenSource{}}
+			})
+
+			It("does not call the rest api", func() {
+				_, err := blaiseRestApi.GetCaseStatus(context.Background(), "dst2106a", "100001")
+				Expect(err).To(MatchError("could not get an ID token: metadata server unavailable"))
+				Expect(requests).To(Equal(0))
+			})
+		})
+	})
+})
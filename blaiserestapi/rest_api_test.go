@@ -1,10 +1,14 @@
 package blaiserestapi_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
+	serverparkpkg "github.com/ONSdigital/blaise-cawi-portal/serverpark"
 	"github.com/jarcoal/httpmock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -38,7 +42,7 @@ var _ = Describe("Blaise rest api endpoints", func() {
 			})
 
 			It("returns a NotFound error", func() {
-				instrumentSettings, err := blaiseRestApi.GetInstrumentSettings(instrumentName)
+				instrumentSettings, err := blaiseRestApi.GetInstrumentSettings(context.Background(), instrumentName)
 				Expect(err).To(MatchError("instrument not found"))
 				Expect(instrumentSettings).To(BeEmpty())
 			})
@@ -56,13 +60,132 @@ var _ = Describe("Blaise rest api endpoints", func() {
 			})
 
 			It("returns instrument settings", func() {
-				instrumentSettings, err := blaiseRestApi.GetInstrumentSettings(instrumentName)
+				instrumentSettings, err := blaiseRestApi.GetInstrumentSettings(context.Background(), instrumentName)
 				Expect(err).To(BeNil())
 				Expect(instrumentSettings).To(HaveLen(1))
 				Expect(instrumentSettings[0].Type).To(Equal("StrictInterviewing"))
 				Expect(instrumentSettings[0].SessionTimeout).To(Equal(15))
 			})
 		})
+
+		Context("when the rest api does not respond in time", func() {
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("GET", fmt.Sprintf("%s/api/v2/serverparks/%s/questionnaires/%s/settings", restApiUrl, serverpark, instrumentName),
+					func(req *http.Request) (*http.Response, error) {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					})
+			})
+
+			It("gives up at the deadline", func() {
+				timeoutRestApi := &blaiserestapi.BlaiseRestApi{
+					BaseUrl:    restApiUrl,
+					Serverpark: serverpark,
+					Client:     &http.Client{},
+					Timeout:    10 * time.Millisecond,
+				}
+				_, err := timeoutRestApi.GetInstrumentSettings(context.Background(), instrumentName)
+				Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Get instrument settings from an instrument's server park", func() {
+		var routedRestApi = &blaiserestapi.BlaiseRestApi{
+			BaseUrl:    restApiUrl,
+			Serverpark: serverpark,
+			Serverparks: &serverparkpkg.Resolver{
+				Default: serverparkpkg.Route{Serverpark: serverpark},
+				Routes:  map[string]serverparkpkg.Route{"lol*": {Serverpark: "cats"}},
+			},
+			Client: &http.Client{},
+		}
+
+		JustBeforeEach(func() {
+			httpmock.RegisterResponder("GET", fmt.Sprintf("%s/api/v2/serverparks/cats/questionnaires/%s/settings", restApiUrl, instrumentName),
+				httpmock.NewJsonResponderOrPanic(200, blaiserestapi.InstrumentSettings{{Type: "StrictInterviewing"}}))
+			httpmock.RegisterResponder("GET", fmt.Sprintf("%s/api/v2/serverparks/%s/questionnaires/dogs/settings", restApiUrl, serverpark),
+				httpmock.NewJsonResponderOrPanic(200, blaiserestapi.InstrumentSettings{{Type: "Default"}}))
+		})
+
+		It("looks up routed instruments on their server park", func() {
+			instrumentSettings, err := routedRestApi.GetInstrumentSettings(context.Background(), instrumentName)
+			Expect(err).To(BeNil())
+			Expect(instrumentSettings[0].Type).To(Equal("StrictInterviewing"))
+		})
+
+		It("looks up other instruments on the default server park", func() {
+			instrumentSettings, err := routedRestApi.GetInstrumentSettings(context.Background(), "dogs")
+			Expect(err).To(BeNil())
+			Expect(instrumentSettings[0].Type).To(Equal("Default"))
+		})
+	})
+})
+
+var _ = Describe("Get case status", func() {
+	var (
+		restApiUrl     = "http://localhost"
+		serverpark     = "foobar"
+		instrumentName = "lolcats"
+		caseID         = "100001"
+		caseStatusUrl  = fmt.Sprintf("%s/api/v2/serverparks/%s/questionnaires/%s/cases/%s/status", restApiUrl, serverpark, instrumentName, caseID)
+		blaiseRestApi  = &blaiserestapi.BlaiseRestApi{
+			BaseUrl:    restApiUrl,
+			Serverpark: serverpark,
+			Client:     &http.Client{},
+		}
+	)
+
+	BeforeEach(func() {
+		httpmock.Activate()
+	})
+
+	AfterEach(func() {
+		httpmock.DeactivateAndReset()
+	})
+
+	Context("when the case does not exist", func() {
+		BeforeEach(func() {
+			httpmock.RegisterResponder("GET", caseStatusUrl, httpmock.NewBytesResponder(404, []byte{}))
+		})
+
+		It("returns a case not found error", func() {
+			_, err := blaiseRestApi.GetCaseStatus(context.Background(), instrumentName, caseID)
+			Expect(err).To(MatchError(blaiserestapi.CaseNotFoundError))
+		})
+	})
+
+	Context("when the case exists", func() {
+		BeforeEach(func() {
+			httpmock.RegisterResponder("GET", caseStatusUrl,
+				httpmock.NewStringResponder(200, `{"primaryKey": "100001", "outcome": 110}`))
+		})
+
+		It("returns the case status", func() {
+			caseStatus, err := blaiseRestApi.GetCaseStatus(context.Background(), instrumentName, caseID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(caseStatus.PrimaryKey).To(Equal(caseID))
+			Expect(caseStatus.Completed()).To(BeTrue())
+		})
+	})
+
+	Context("when the rest api errors", func() {
+		BeforeEach(func() {
+			httpmock.RegisterResponder("GET", caseStatusUrl, httpmock.NewStringResponder(500, `oops`))
+		})
+
+		It("returns an error", func() {
+			_, err := blaiseRestApi.GetCaseStatus(context.Background(), instrumentName, caseID)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("CaseStatus.Completed", func() {
+	It("is only true for a completed outcome", func() {
+		Expect(blaiserestapi.CaseStatus{Outcome: blaiserestapi.OUTCOME_COMPLETED}.Completed()).To(BeTrue())
+		Expect(blaiserestapi.CaseStatus{Outcome: blaiserestapi.OUTCOME_PARTIAL}.Completed()).To(BeFalse())
+		Expect(blaiserestapi.CaseStatus{}.Completed()).To(BeFalse())
 	})
 })
 
@@ -2,38 +2,77 @@ package busapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/credentials"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
 )
 
+// UPSTREAM names BUS in the errors GetUacInfo returns
+const UPSTREAM = "BUS"
+
 //Generate mocks by running "go generate ./..."
 //go:generate mockery --name BusApiInterface
 type BusApiInterface interface {
-	GetUacInfo(string) (UacInfo, error)
+	GetUacInfo(context.Context, string) (UacInfo, error)
 }
 
+// BusApi calls BUS, giving up on a call after Timeout, if set. Requests carry
+// Credentials, if set.
 type BusApi struct {
-	BaseUrl string
-	Client  *http.Client
+	BaseUrl     string
+	Credentials credentials.CredentialsInterface
+	Client      *http.Client
+	Timeout     time.Duration
 }
 
 type UACRequest struct {
 	UAC string `json:"uac"`
 }
 
-func (busApi *BusApi) GetUacInfo(uac string) (UacInfo, error) {
-	response, err := busApi.doGetUacInfo(uac)
+// GetUacInfo looks up the case for a UAC. Failures are returned as one of
+// upstream.NotFoundError, UnauthorizedError, UnavailableError or
+// MalformedResponseError. If the caller's context ends first, its error is
+// returned instead, as that is no fault of BUS.
+func (busApi *BusApi) GetUacInfo(ctx context.Context, uac string) (UacInfo, error) {
+	requestCtx, cancel := utils.WithTimeout(ctx, busApi.Timeout)
+	defer cancel()
+
+	response, err := busApi.doGetUacInfo(requestCtx, uac)
 	if err != nil {
-		return UacInfo{}, err
+		if ctx.Err() != nil {
+			return UacInfo{}, ctx.Err()
+		}
+		return UacInfo{}, &upstream.UnavailableError{Upstream: UPSTREAM, Err: err}
 	}
+	defer response.Body.Close()
 
-	if response.StatusCode == http.StatusNotFound {
-		return UacInfo{}, nil
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return UacInfo{}, ctx.Err()
+		}
+		return UacInfo{}, &upstream.UnavailableError{Upstream: UPSTREAM, Response: upstream.NewResponse(response.StatusCode, nil), Err: err}
 	}
 
-	return busApi.marshalUacResponse(response)
+	switch {
+	case response.StatusCode == http.StatusOK:
+		return busApi.unmarshalUacResponse(response.StatusCode, body)
+	case response.StatusCode == http.StatusNotFound:
+		return UacInfo{}, &upstream.NotFoundError{Upstream: UPSTREAM, Response: upstream.NewResponse(response.StatusCode, body)}
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return UacInfo{}, &upstream.UnauthorizedError{Upstream: UPSTREAM, Response: upstream.NewResponse(response.StatusCode, body)}
+	case response.StatusCode >= http.StatusInternalServerError:
+		return UacInfo{}, &upstream.UnavailableError{Upstream: UPSTREAM, Response: upstream.NewResponse(response.StatusCode, body)}
+	default:
+		return UacInfo{}, &upstream.MalformedResponseError{Upstream: UPSTREAM, Response: upstream.NewResponse(response.StatusCode, body)}
+	}
 }
 
 func (busApi *BusApi) getUACInfoUrl() (url string) {
@@ -42,14 +81,14 @@ func (busApi *BusApi) getUACInfoUrl() (url string) {
 	)
 }
 
-func (busApi *BusApi) doGetUacInfo(uac string) (*http.Response, error) {
+func (busApi *BusApi) doGetUacInfo(ctx context.Context, uac string) (*http.Response, error) {
 	uacRequest := UACRequest{UAC: uac}
 	uacJSON, err := json.Marshal(uacRequest)
 	if err != nil {
 		return nil, fmt.Errorf("unable to Marshal error")
 	}
 
-	request, err := http.NewRequest("POST", busApi.getUACInfoUrl(),
+	request, err := http.NewRequestWithContext(ctx, "POST", busApi.getUACInfoUrl(),
 		bytes.NewReader(uacJSON),
 	)
 
@@ -57,20 +96,20 @@ func (busApi *BusApi) doGetUacInfo(uac string) (*http.Response, error) {
 		return nil, err
 	}
 
+	if busApi.Credentials != nil {
+		if err := busApi.Credentials.Authorise(request); err != nil {
+			return nil, err
+		}
+	}
+
 	return busApi.Client.Do(request)
 }
 
-func (busApi *BusApi) marshalUacResponse(response *http.Response) (UacInfo, error) {
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return UacInfo{}, fmt.Errorf("unable to read body")
-	}
-	defer response.Body.Close()
-
+func (busApi *BusApi) unmarshalUacResponse(statusCode int, body []byte) (UacInfo, error) {
 	var uacInfo UacInfo
-	err = json.Unmarshal(body, &uacInfo)
+	err := json.Unmarshal(body, &uacInfo)
 	if err != nil {
-		return UacInfo{}, fmt.Errorf("unable To Unmarshal Json")
+		return UacInfo{}, &upstream.MalformedResponseError{Upstream: UPSTREAM, Response: upstream.NewResponse(statusCode, body), Err: err}
 	}
 	return uacInfo, nil
 }
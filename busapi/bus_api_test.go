@@ -1,10 +1,17 @@
 package busapi_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+	"github.com/ONSdigital/blaise-cawi-portal/credentials"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
 	"github.com/jarcoal/httpmock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -36,25 +43,198 @@ var _ = Describe("BUS API", func() {
 			})
 
 			It("Returns UAC Info for a valid UAC", func() {
-				uacInfo, err := busApi.GetUacInfo(uac)
+				uacInfo, err := busApi.GetUacInfo(context.Background(), uac)
 				Expect(err).To(BeNil())
 				Expect(uacInfo.InstrumentName).To(Equal("foo"))
 				Expect(uacInfo.CaseID).To(Equal("bar"))
 			})
 		})
 
+		Context("the UAC is not known", func() {
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
+					httpmock.NewStringResponder(404, `{"error": "UAC not found"}`))
+			})
+
+			It("Returns a not found error with the response", func() {
+				_, err := busApi.GetUacInfo(context.Background(), uac)
+				var notFoundError *upstream.NotFoundError
+				Expect(errors.As(err, &notFoundError)).To(BeTrue())
+				Expect(notFoundError.StatusCode).To(Equal(404))
+				Expect(notFoundError.Body).To(Equal(`{"error": "UAC not found"}`))
+				Expect(busapi.Retryable(err)).To(BeFalse())
+			})
+		})
+
+		Context("the portal is not authorised", func() {
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
+					httpmock.NewStringResponder(403, "Forbidden"))
+			})
+
+			It("Returns an unauthorized error", func() {
+				_, err := busApi.GetUacInfo(context.Background(), uac)
+				Expect(err).To(MatchError(&upstream.UnauthorizedError{Upstream: busapi.UPSTREAM, Response: upstream.Response{StatusCode: 403, Body: "Forbidden"}}))
+				Expect(busapi.Retryable(err)).To(BeFalse())
+			})
+		})
+
 		Context("bad response is returned", func() {
 			JustBeforeEach(func() {
 				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
-					httpmock.NewJsonResponderOrPanic(500, "nil"))
+					httpmock.NewStringResponder(200, "<html>not json</html>"))
 			})
 
-			It("Returns a an error and an empty uac info struct", func() {
-				uacInfo, err := busApi.GetUacInfo(uac)
-				Expect(err).To(MatchError("unable To Unmarshal Json"))
+			It("Returns a malformed response error and an empty uac info struct", func() {
+				uacInfo, err := busApi.GetUacInfo(context.Background(), uac)
+				var malformedError *upstream.MalformedResponseError
+				Expect(errors.As(err, &malformedError)).To(BeTrue())
+				Expect(malformedError.StatusCode).To(Equal(200))
+				Expect(malformedError.Body).To(Equal("<html>not json</html>"))
 				Expect(uacInfo.InstrumentName).To(Equal(""))
 				Expect(uacInfo.CaseID).To(Equal(""))
 			})
 		})
+
+		Context("an unexpected status is returned", func() {
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
+					httpmock.NewStringResponder(400, "Bad Request"))
+			})
+
+			It("Returns a malformed response error", func() {
+				_, err := busApi.GetUacInfo(context.Background(), uac)
+				Expect(err).To(MatchError("unexpected response from BUS (status 400)"))
+			})
+		})
+
+		Context("a server error is returned", func() {
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
+					httpmock.NewStringResponder(503, strings.Repeat("x", 1000)))
+			})
+
+			It("Returns a retryable upstream unavailable error with a truncated body", func() {
+				_, err := busApi.GetUacInfo(context.Background(), uac)
+				var unavailableError *upstream.UnavailableError
+				Expect(errors.As(err, &unavailableError)).To(BeTrue())
+				Expect(unavailableError.StatusCode).To(Equal(503))
+				Expect(unavailableError.Body).To(HaveLen(upstream.MAX_ERROR_BODY_LENGTH + 3))
+				Expect(busapi.Retryable(err)).To(BeTrue())
+			})
+		})
+
+		Context("BUS does not respond in time", func() {
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
+					func(req *http.Request) (*http.Response, error) {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					})
+			})
+
+			It("Returns a retryable upstream unavailable error after the timeout", func() {
+				timeoutBusApi := &busapi.BusApi{BaseUrl: baseUrl, Client: &http.Client{}, Timeout: 10 * time.Millisecond}
+				_, err := timeoutBusApi.GetUacInfo(context.Background(), uac)
+				Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+				Expect(busapi.Retryable(err)).To(BeTrue())
+			})
+
+			It("Returns the caller's error when the caller gives up first", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				_, err := busApi.GetUacInfo(ctx, uac)
+				Expect(err).To(Equal(context.Canceled))
+				Expect(busapi.Retryable(err)).To(BeFalse())
+			})
+		})
+
+		Context("the caller gives up while the response is being read", func() {
+			var cancel context.CancelFunc
+
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
+					func(req *http.Request) (*http.Response, error) {
+						return &http.Response{StatusCode: 200, Body: cancellingBody{cancel: cancel}}, nil
+					})
+			})
+
+			It("Returns the caller's error", func() {
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				_, err := busApi.GetUacInfo(ctx, uac)
+				Expect(err).To(Equal(context.Canceled))
+				Expect(busapi.Retryable(err)).To(BeFalse())
+			})
+		})
+
+		Context("BUS cannot be reached", func() {
+			JustBeforeEach(func() {
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/uacs/uac", baseUrl),
+					httpmock.NewErrorResponder(errors.New("connection refused")))
+			})
+
+			It("Returns a retryable upstream unavailable error", func() {
+				_, err := busApi.GetUacInfo(context.Background(), uac)
+				var unavailableError *upstream.UnavailableError
+				Expect(errors.As(err, &unavailableError)).To(BeTrue())
+				Expect(unavailableError.StatusCode).To(Equal(0))
+				Expect(err.Error()).To(ContainSubstring("connection refused"))
+				Expect(busapi.Retryable(err)).To(BeTrue())
+			})
+		})
 	})
 })
+
+var _ = Describe("BUS API credentials", func() {
+	var (
+		busServer           *httptest.Server
+		authorizationHeader string
+		busApi              *busapi.BusApi
+	)
+
+	BeforeEach(func() {
+		busServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorizationHeader = r.Header.Get("Authorization")
+			if authorizationHeader != "Bearer let-me-in" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"instrument_name": "foo", "case_id": "bar"}`))
+		}))
+		busApi = &busapi.BusApi{BaseUrl: busServer.URL, Client: busServer.Client()}
+	})
+
+	AfterEach(func() {
+		busServer.Close()
+	})
+
+	It("sends the credentials to BUS", func() {
+		busApi.Credentials = &credentials.BearerToken{Token: "let-me-in"}
+		uacInfo, err := busApi.GetUacInfo(context.Background(), "123456789012")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(uacInfo.InstrumentName).To(Equal("foo"))
+		Expect(authorizationHeader).To(Equal("Bearer let-me-in"))
+	})
+
+	It("sends nothing without credentials", func() {
+		busApi.Credentials = credentials.NoCredentials{}
+		_, err := busApi.GetUacInfo(context.Background(), "123456789012")
+		var unauthorizedError *upstream.UnauthorizedError
+		Expect(errors.As(err, &unauthorizedError)).To(BeTrue())
+		Expect(authorizationHeader).To(BeEmpty())
+	})
+})
+
+// cancellingBody cancels the caller's context as the body is read, as a
+// caller giving up part way through a response would
+type cancellingBody struct {
+	cancel context.CancelFunc
+}
+
+func (body cancellingBody) Read([]byte) (int, error) {
+	body.cancel()
+	return 0, errors.New("connection reset")
+}
+
+func (body cancellingBody) Close() error { return nil }
@@ -0,0 +1,92 @@
+package busapi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/circuitbreaker"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+	"go.uber.org/zap"
+)
+
+// ResilientBusApi wraps a BusApiInterface, retrying calls that fail because
+// BUS is unavailable and failing fast through Breaker once BUS has been
+// unavailable for several calls in a row. Retries wait RetryBackoff, doubling
+// each time, with jitter so respondents do not all retry at once.
+type ResilientBusApi struct {
+	BusApi       BusApiInterface
+	Retries      int
+	RetryBackoff time.Duration
+	Breaker      *circuitbreaker.Breaker
+	Logger       *zap.Logger
+}
+
+func NewResilientBusApi(busApi BusApiInterface, retries int, retryBackoff time.Duration,
+	breakerThreshold int, breakerCooldown time.Duration, logger *zap.Logger) *ResilientBusApi {
+	return &ResilientBusApi{
+		BusApi:       busApi,
+		Retries:      retries,
+		RetryBackoff: retryBackoff,
+		Logger:       logger,
+		Breaker: circuitbreaker.NewBreaker(breakerThreshold, breakerCooldown, func(from, to circuitbreaker.State) {
+			logger.Warn("BUS circuit breaker changed state",
+				zap.String("From", string(from)),
+				zap.String("To", string(to)),
+			)
+		}),
+	}
+}
+
+func (resilientBusApi *ResilientBusApi) GetUacInfo(ctx context.Context, uac string) (UacInfo, error) {
+	if resilientBusApi.Breaker == nil {
+		return resilientBusApi.getUacInfoWithRetries(ctx, uac)
+	}
+	var uacInfo UacInfo
+	err := resilientBusApi.Breaker.Execute(func() error {
+		var err error
+		uacInfo, err = resilientBusApi.getUacInfoWithRetries(ctx, uac)
+		return err
+	}, Retryable)
+	return uacInfo, err
+}
+
+// getUacInfoWithRetries stops retrying as soon as the caller's context ends
+func (resilientBusApi *ResilientBusApi) getUacInfoWithRetries(ctx context.Context, uac string) (UacInfo, error) {
+	for attempt := 0; ; attempt++ {
+		uacInfo, err := resilientBusApi.BusApi.GetUacInfo(ctx, uac)
+		if err == nil || !Retryable(err) || attempt >= resilientBusApi.Retries {
+			return uacInfo, err
+		}
+		delay := resilientBusApi.backoff(attempt)
+		resilientBusApi.Logger.Info("Retrying BUS request",
+			zap.Int("Attempt", attempt+1),
+			zap.Duration("Delay", delay),
+			zap.Error(err),
+		)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return UacInfo{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff waits between half and all of the doubled backoff for the attempt
+func (resilientBusApi *ResilientBusApi) backoff(attempt int) time.Duration {
+	delay := resilientBusApi.RetryBackoff << uint(attempt)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Retryable reports whether an error means BUS could not be reached or could
+// not handle the request, rather than a problem with the request itself
+func Retryable(err error) bool {
+	var unavailableError *upstream.UnavailableError
+	return errors.As(err, &unavailableError)
+}
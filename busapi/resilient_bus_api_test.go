@@ -0,0 +1,105 @@
+package busapi_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+	"github.com/ONSdigital/blaise-cawi-portal/busapi/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/circuitbreaker"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Resilient BUS API", func() {
+	var (
+		mockBusApi      *mocks.BusApiInterface
+		resilientBusApi *busapi.ResilientBusApi
+		observedLogs    *observer.ObservedLogs
+		observedZapCore zapcore.Core
+		uac             = "123456789012"
+		unavailable     = &upstream.UnavailableError{Upstream: busapi.UPSTREAM, Response: upstream.Response{StatusCode: 503}}
+		uacInfo         = busapi.UacInfo{InstrumentName: "foo", CaseID: "bar"}
+	)
+
+	BeforeEach(func() {
+		observedZapCore, observedLogs = observer.New(zap.InfoLevel)
+		mockBusApi = &mocks.BusApiInterface{}
+		resilientBusApi = busapi.NewResilientBusApi(mockBusApi, 2, time.Millisecond, 2, time.Minute, zap.New(observedZapCore))
+	})
+
+	It("retries when BUS is unavailable", func() {
+		mockBusApi.On("GetUacInfo", mock.Anything, uac).Once().Return(busapi.UacInfo{}, unavailable)
+		mockBusApi.On("GetUacInfo", mock.Anything, uac).Once().Return(uacInfo, nil)
+
+		Expect(resilientBusApi.GetUacInfo(context.Background(), uac)).To(Equal(uacInfo))
+		mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 2)
+		Expect(observedLogs.FilterMessage("Retrying BUS request").Len()).To(Equal(1))
+	})
+
+	It("gives up after the configured number of retries", func() {
+		mockBusApi.On("GetUacInfo", mock.Anything, uac).Return(busapi.UacInfo{}, unavailable)
+
+		_, err := resilientBusApi.GetUacInfo(context.Background(), uac)
+		Expect(err).To(MatchError(unavailable))
+		mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 3)
+	})
+
+	It("stops retrying once the caller has given up", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		mockBusApi.On("GetUacInfo", ctx, uac).Run(func(mock.Arguments) {
+			cancel()
+		}).Return(busapi.UacInfo{}, unavailable)
+
+		_, err := resilientBusApi.GetUacInfo(ctx, uac)
+		Expect(err).To(Equal(context.Canceled))
+		mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 1)
+	})
+
+	It("does not retry errors that are not BUS being unavailable", func() {
+		mockBusApi.On("GetUacInfo", mock.Anything, uac).Return(busapi.UacInfo{}, &upstream.NotFoundError{Upstream: busapi.UPSTREAM, Response: upstream.Response{StatusCode: 404}})
+
+		_, err := resilientBusApi.GetUacInfo(context.Background(), uac)
+		Expect(err).To(MatchError("not found in BUS (status 404)"))
+		mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 1)
+		Expect(resilientBusApi.Breaker.State()).To(Equal(circuitbreaker.CLOSED))
+	})
+
+	It("fails fast once BUS has been unavailable repeatedly", func() {
+		mockBusApi.On("GetUacInfo", mock.Anything, uac).Return(busapi.UacInfo{}, unavailable)
+
+		resilientBusApi.GetUacInfo(context.Background(), uac)
+		resilientBusApi.GetUacInfo(context.Background(), uac)
+		_, err := resilientBusApi.GetUacInfo(context.Background(), uac)
+
+		Expect(err).To(MatchError(circuitbreaker.OpenError))
+		mockBusApi.AssertNumberOfCalls(GinkgoT(), "GetUacInfo", 6)
+
+		stateChanges := observedLogs.FilterMessage("BUS circuit breaker changed state")
+		Expect(stateChanges.Len()).To(Equal(1))
+		Expect(stateChanges.All()[0].ContextMap()["From"]).To(Equal("closed"))
+		Expect(stateChanges.All()[0].ContextMap()["To"]).To(Equal("open"))
+		Expect(stateChanges.All()[0].Level).To(Equal(zap.WarnLevel))
+	})
+
+	It("does not close the breaker when the caller gives up on a trial call", func() {
+		resilientBusApi = busapi.NewResilientBusApi(mockBusApi, 0, time.Millisecond, 1, 0, zap.New(observedZapCore))
+		ctx, cancel := context.WithCancel(context.Background())
+		mockBusApi.On("GetUacInfo", context.Background(), uac).Once().Return(busapi.UacInfo{}, unavailable)
+		mockBusApi.On("GetUacInfo", ctx, uac).Run(func(mock.Arguments) {
+			cancel()
+		}).Return(busapi.UacInfo{}, context.Canceled)
+
+		resilientBusApi.GetUacInfo(context.Background(), uac)
+		Expect(resilientBusApi.Breaker.State()).To(Equal(circuitbreaker.OPEN))
+
+		_, err := resilientBusApi.GetUacInfo(ctx, uac)
+		Expect(err).To(Equal(context.Canceled))
+		Expect(resilientBusApi.Breaker.State()).To(Equal(circuitbreaker.HALF_OPEN))
+	})
+})
@@ -0,0 +1,142 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	CLOSED    State = "closed"
+	OPEN      State = "open"
+	HALF_OPEN State = "half-open"
+)
+
+var OpenError = errors.New("circuit breaker is open")
+
+type State string
+
+// Breaker stops calls to a dependency once it has failed Threshold times in a
+// row, failing fast instead. After Cooldown it lets one call through to see
+// whether the dependency has recovered: success closes the breaker again and
+// failure reopens it.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	// OnStateChange, if set, is called whenever the breaker changes state. It
+	// must not call back into the breaker.
+	OnStateChange func(from, to State)
+
+	mutex    sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func NewBreaker(threshold int, cooldown time.Duration, onStateChange func(from, to State)) *Breaker {
+	return &Breaker{
+		Threshold:     threshold,
+		Cooldown:      cooldown,
+		OnStateChange: onStateChange,
+	}
+}
+
+// Execute runs call unless the breaker is open, in which case it returns
+// OpenError. failed decides which errors count against the dependency; other
+// errors are the dependency answering, and count as a success. The caller's
+// context ending before the dependency answers counts as neither, so a trial
+// call that is given up on leaves the breaker half-open for the next one.
+func (breaker *Breaker) Execute(call func() error, failed func(error) bool) error {
+	if err := breaker.allow(); err != nil {
+		return err
+	}
+	err := call()
+	switch {
+	case err == nil:
+		breaker.success()
+	case failed(err):
+		breaker.failure()
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		breaker.abandoned()
+	default:
+		breaker.success()
+	}
+	return err
+}
+
+func (breaker *Breaker) State() State {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	return breaker.currentState()
+}
+
+func (breaker *Breaker) allow() error {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	switch breaker.currentState() {
+	case OPEN:
+		if time.Now().Sub(breaker.openedAt) < breaker.Cooldown {
+			return OpenError
+		}
+		breaker.setState(HALF_OPEN)
+		breaker.trial = true
+		return nil
+	case HALF_OPEN:
+		if breaker.trial {
+			return OpenError
+		}
+		breaker.trial = true
+	}
+	return nil
+}
+
+func (breaker *Breaker) success() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.failures = 0
+	breaker.trial = false
+	if breaker.currentState() != CLOSED {
+		breaker.setState(CLOSED)
+	}
+}
+
+// abandoned lets another trial call through, without recording an outcome
+func (breaker *Breaker) abandoned() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.trial = false
+}
+
+func (breaker *Breaker) failure() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.failures++
+	breaker.trial = false
+	if breaker.currentState() == HALF_OPEN || breaker.failures >= breaker.Threshold {
+		breaker.openedAt = time.Now()
+		if breaker.currentState() != OPEN {
+			breaker.setState(OPEN)
+		}
+	}
+}
+
+func (breaker *Breaker) currentState() State {
+	if breaker.state == "" {
+		return CLOSED
+	}
+	return breaker.state
+}
+
+func (breaker *Breaker) setState(state State) {
+	from := breaker.currentState()
+	breaker.state = state
+	if breaker.OnStateChange != nil {
+		breaker.OnStateChange(from, state)
+	}
+}
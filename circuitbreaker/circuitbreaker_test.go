@@ -0,0 +1,125 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/circuitbreaker"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Breaker", func() {
+	var (
+		breaker     *circuitbreaker.Breaker
+		transitions []string
+		calls       int
+		failing     = errors.New("failing")
+		ignored     = errors.New("ignored")
+	)
+
+	isFailure := func(err error) bool {
+		return err == failing
+	}
+
+	call := func(err error) error {
+		return breaker.Execute(func() error {
+			calls++
+			return err
+		}, isFailure)
+	}
+
+	BeforeEach(func() {
+		transitions = []string{}
+		calls = 0
+		breaker = circuitbreaker.NewBreaker(3, 20*time.Millisecond, func(from, to circuitbreaker.State) {
+			transitions = append(transitions, string(from)+"->"+string(to))
+		})
+	})
+
+	It("opens after the threshold of consecutive failures and fails fast", func() {
+		for i := 0; i < 3; i++ {
+			Expect(call(failing)).To(MatchError(failing))
+		}
+		Expect(breaker.State()).To(Equal(circuitbreaker.OPEN))
+
+		Expect(call(nil)).To(MatchError(circuitbreaker.OpenError))
+		Expect(calls).To(Equal(3))
+		Expect(transitions).To(Equal([]string{"closed->open"}))
+	})
+
+	It("does not count errors that are not failures", func() {
+		for i := 0; i < 5; i++ {
+			Expect(call(ignored)).To(MatchError(ignored))
+		}
+		Expect(breaker.State()).To(Equal(circuitbreaker.CLOSED))
+	})
+
+	It("does not count the caller giving up as a success", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Expect(call(failing)).ToNot(Succeed())
+		Expect(call(failing)).ToNot(Succeed())
+		Expect(call(ctx.Err())).To(MatchError(context.Canceled))
+		Expect(call(failing)).ToNot(Succeed())
+		Expect(breaker.State()).To(Equal(circuitbreaker.OPEN))
+	})
+
+	It("resets the count after a success", func() {
+		Expect(call(failing)).ToNot(Succeed())
+		Expect(call(failing)).ToNot(Succeed())
+		Expect(call(nil)).To(Succeed())
+		Expect(call(failing)).ToNot(Succeed())
+		Expect(breaker.State()).To(Equal(circuitbreaker.CLOSED))
+	})
+
+	Context("once the cooldown has passed", func() {
+		BeforeEach(func() {
+			for i := 0; i < 3; i++ {
+				call(failing)
+			}
+			time.Sleep(25 * time.Millisecond)
+		})
+
+		It("closes again when a trial call succeeds", func() {
+			Expect(call(nil)).To(Succeed())
+			Expect(breaker.State()).To(Equal(circuitbreaker.CLOSED))
+			Expect(transitions).To(Equal([]string{"closed->open", "open->half-open", "half-open->closed"}))
+		})
+
+		It("reopens when the trial call fails", func() {
+			Expect(call(failing)).To(MatchError(failing))
+			Expect(breaker.State()).To(Equal(circuitbreaker.OPEN))
+			Expect(call(nil)).To(MatchError(circuitbreaker.OpenError))
+			Expect(transitions).To(Equal([]string{"closed->open", "open->half-open", "half-open->open"}))
+		})
+
+		It("stays half-open when the caller gives up on the trial call", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			Expect(call(ctx.Err())).To(MatchError(context.Canceled))
+			Expect(breaker.State()).To(Equal(circuitbreaker.HALF_OPEN))
+
+			Expect(call(nil)).To(Succeed())
+			Expect(breaker.State()).To(Equal(circuitbreaker.CLOSED))
+			Expect(calls).To(Equal(5))
+		})
+
+		It("stays half-open when the caller's deadline passes during the trial call", func() {
+			Expect(call(context.DeadlineExceeded)).To(MatchError(context.DeadlineExceeded))
+			Expect(breaker.State()).To(Equal(circuitbreaker.HALF_OPEN))
+			Expect(transitions).To(Equal([]string{"closed->open", "open->half-open"}))
+		})
+
+		It("lets only one trial call through at a time", func() {
+			Expect(breaker.Execute(func() error {
+				Expect(call(nil)).To(MatchError(circuitbreaker.OpenError))
+				return nil
+			}, isFailure)).To(Succeed())
+		})
+	})
+})
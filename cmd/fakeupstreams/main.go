@@ -0,0 +1,37 @@
+// fakeupstreams serves stand-ins for BUS, the Blaise REST API and CATI from
+// a fixtures file, so the portal can be run end to end without GCP. Point the
+// portal at it with:
+//
+//	BUS_URL=http://localhost:8081/bus
+//	BLAISE_REST_API=http://localhost:8081/rest
+//	CATI_URL=http://localhost:8081/cati
+//
+// and DEV_MODE=true without a BUS_CLIENT_ID.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/ONSdigital/blaise-cawi-portal/fakeupstreams"
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8081", "address to listen on")
+	fixturesFile := flag.String("fixtures", "cmd/fakeupstreams/fixtures.json", "fixtures file of UACs and instruments")
+	flag.Parse()
+
+	fixtures, err := fakeupstreams.LoadFixtures(*fixturesFile)
+	if err != nil {
+		log.Fatalf("Error loading fixtures: %s", err)
+	}
+
+	server := fakeupstreams.NewServer(fixtures)
+	server.Use(gin.Logger())
+	log.Printf("Serving BUS on http://%s%s", *addr, fakeupstreams.BUS_PATH)
+	log.Printf("Serving the Blaise REST API on http://%s%s", *addr, fakeupstreams.REST_API_PATH)
+	log.Printf("Serving CATI on http://%s%s", *addr, fakeupstreams.CATI_PATH)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
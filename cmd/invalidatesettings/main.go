@@ -0,0 +1,35 @@
+// invalidatesettings makes every instance of the portal fetch the settings of
+// each instrument named on the command line again, for when it has been
+// redeployed, rather than waiting for INSTRUMENT_SETTINGS_CACHE_TTL. Instances
+// pick the change up within INSTRUMENT_SETTINGS_POLL_INTERVAL.
+//
+//	invalidatesettings dst2101a [lms2102_bk1 ...]
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Expected the names of the instruments to invalidate")
+	}
+
+	redisSessionDB := os.Getenv("REDIS_SESSION_DB")
+	if redisSessionDB == "" {
+		redisSessionDB = "localhost:6379"
+	}
+	store := kvstore.NewRedisStore(redisSessionDB)
+
+	for _, instrumentName := range flag.Args() {
+		if err := blaiserestapi.InvalidateInstrumentSettings(store, instrumentName); err != nil {
+			log.Fatalf("Error invalidating settings for %s: %s", instrumentName, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// linktoken prints a one-time login link path for each UAC read from stdin,
+// for printing as QR codes on letters. Links are signed with the same
+// LINK_TOKEN_SECRET the portal is configured with.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+)
+
+func main() {
+	ttl := flag.Duration("ttl", 30*24*time.Hour, "how long each link is valid for")
+	flag.Parse()
+
+	secret := os.Getenv("LINK_TOKEN_SECRET")
+	if secret == "" {
+		log.Fatal("LINK_TOKEN_SECRET must be set")
+	}
+	linkTokenCrypto := &authenticate.LinkTokenCrypto{LinkTokenSecret: secret}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		uac := scanner.Text()
+		if uac == "" {
+			continue
+		}
+		linkToken, err := linkTokenCrypto.EncryptLinkToken(uac, *ttl)
+		if err != nil {
+			log.Fatalf("Error creating link token: %s", err)
+		}
+		fmt.Printf("/auth/link/%s\n", linkToken)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error reading UACs: %s", err)
+	}
+}
@@ -0,0 +1,72 @@
+// maintenance turns maintenance on or off for every instance of the portal,
+// by changing the settings they share in the session database. Instances pick
+// the change up within MAINTENANCE_RELOAD_INTERVAL.
+//
+//	maintenance [-instrument dst21*] [-until 2021-06-01T09:00:00+01:00] on|off|show
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	"github.com/ONSdigital/blaise-cawi-portal/maintenance"
+)
+
+func main() {
+	redisSessionDB := os.Getenv("REDIS_SESSION_DB")
+	if redisSessionDB == "" {
+		redisSessionDB = "localhost:6379"
+	}
+	instrument := flag.String("instrument", "", "instrument name or prefix* to change, rather than everything")
+	until := flag.String("until", "", "when maintenance is expected to end, in RFC 3339 format")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("Expected one of on, off or show")
+	}
+
+	store := kvstore.NewRedisStore(redisSessionDB)
+	settings, err := maintenance.LoadSettings(store)
+	if err != nil {
+		log.Fatalf("Error loading maintenance settings: %s", err)
+	}
+
+	var window maintenance.Window
+	switch flag.Arg(0) {
+	case "on":
+		window.Enabled = true
+		if *until != "" {
+			untilTime, err := time.Parse(time.RFC3339, *until)
+			if err != nil {
+				log.Fatalf("Error reading -until: %s", err)
+			}
+			window.Until = &untilTime
+		}
+	case "off":
+	case "show":
+		settingsJSON, _ := json.MarshalIndent(settings, "", "  ")
+		fmt.Println(string(settingsJSON))
+		return
+	default:
+		log.Fatalf("Unknown command %q, expected one of on, off or show", flag.Arg(0))
+	}
+
+	if *instrument == "" {
+		settings.Global = window
+	} else if window.Enabled {
+		if settings.Instruments == nil {
+			settings.Instruments = map[string]maintenance.Window{}
+		}
+		settings.Instruments[*instrument] = window
+	} else {
+		delete(settings.Instruments, *instrument)
+	}
+
+	if err := maintenance.SaveSettings(store, settings); err != nil {
+		log.Fatalf("Error saving maintenance settings: %s", err)
+	}
+}
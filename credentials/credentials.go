@@ -0,0 +1,81 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/idtoken"
+)
+
+// Ways of authenticating with the services the portal calls
+const (
+	AUTH_NONE     = "none"
+	AUTH_BEARER   = "bearer"
+	AUTH_ID_TOKEN = "idtoken"
+)
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name CredentialsInterface
+type CredentialsInterface interface {
+	Authorise(*http.Request) error
+}
+
+// NoCredentials leaves requests as they are, for services that are only
+// reachable from inside the network or stand-ins for local development
+type NoCredentials struct{}
+
+func (NoCredentials) Authorise(*http.Request) error {
+	return nil
+}
+
+// BearerToken sends the same token with every request
+type BearerToken struct {
+	Token string
+}
+
+func (bearerToken *BearerToken) Authorise(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+bearerToken.Token)
+	return nil
+}
+
+// IDToken sends a Google ID token from TokenSource with every request, such
+// as for a service behind IAP. The token source caches tokens and fetches
+// a new one as each expires.
+type IDToken struct {
+	TokenSource oauth2.TokenSource
+}
+
+func (idToken *IDToken) Authorise(req *http.Request) error {
+	token, err := idToken.TokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("could not get an ID token: %w", err)
+	}
+	token.SetAuthHeader(req)
+	return nil
+}
+
+// NewCredentials returns the credentials for authMode, one of AUTH_NONE,
+// AUTH_BEARER with token, or AUTH_ID_TOKEN for audience
+func NewCredentials(ctx context.Context, authMode, token, audience string) (CredentialsInterface, error) {
+	switch authMode {
+	case "", AUTH_NONE:
+		return NoCredentials{}, nil
+	case AUTH_BEARER:
+		if token == "" {
+			return nil, fmt.Errorf("%s auth needs a token", AUTH_BEARER)
+		}
+		return &BearerToken{Token: token}, nil
+	case AUTH_ID_TOKEN:
+		if audience == "" {
+			return nil, fmt.Errorf("%s auth needs an audience", AUTH_ID_TOKEN)
+		}
+		tokenSource, err := idtoken.NewTokenSource(ctx, audience)
+		if err != nil {
+			return nil, err
+		}
+		return &IDToken{TokenSource: tokenSource}, nil
+	}
+	return nil, fmt.Errorf("unknown auth mode %q, must be one of %s, %s or %s", authMode, AUTH_NONE, AUTH_BEARER, AUTH_ID_TOKEN)
+}
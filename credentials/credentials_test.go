@@ -0,0 +1,67 @@
+package credentials_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ONSdigital/blaise-cawi-portal/credentials"
+	"golang.org/x/oauth2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+	var req *http.Request
+
+	BeforeEach(func() {
+		req, _ = http.NewRequest("GET", "http://localhost", nil)
+	})
+
+	It("leaves requests without credentials alone", func() {
+		Expect(credentials.NoCredentials{}.Authorise(req)).To(Succeed())
+		Expect(req.Header).To(BeEmpty())
+	})
+
+	It("adds a bearer token", func() {
+		Expect((&credentials.BearerToken{Token: "let-me-in"}).Authorise(req)).To(Succeed())
+		Expect(req.Header.Get("Authorization")).To(Equal("Bearer let-me-in"))
+	})
+
+	It("adds an ID token", func() {
+		idToken := &credentials.IDToken{
+			TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "let-me-in", TokenType: "Bearer"}),
+		}
+		Expect(idToken.Authorise(req)).To(Succeed())
+		Expect(req.Header.Get("Authorization")).To(Equal("Bearer let-me-in"))
+	})
+
+	Describe("NewCredentials", func() {
+		It("defaults to no credentials", func() {
+			creds, err := credentials.NewCredentials(context.Background(), "", "", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(creds).To(Equal(credentials.NoCredentials{}))
+		})
+
+		It("makes a bearer token", func() {
+			creds, err := credentials.NewCredentials(context.Background(), credentials.AUTH_BEARER, "let-me-in", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(creds).To(Equal(&credentials.BearerToken{Token: "let-me-in"}))
+		})
+
+		It("needs a token for bearer auth", func() {
+			_, err := credentials.NewCredentials(context.Background(), credentials.AUTH_BEARER, "", "")
+			Expect(err).To(MatchError("bearer auth needs a token"))
+		})
+
+		It("needs an audience for ID token auth", func() {
+			_, err := credentials.NewCredentials(context.Background(), credentials.AUTH_ID_TOKEN, "", "")
+			Expect(err).To(MatchError("idtoken auth needs an audience"))
+		})
+
+		It("rejects unknown auth modes", func() {
+			_, err := credentials.NewCredentials(context.Background(), "basic", "", "")
+			Expect(err).To(MatchError(`unknown auth mode "basic", must be one of none, bearer or idtoken`))
+		})
+	})
+})
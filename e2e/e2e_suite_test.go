@@ -0,0 +1,68 @@
+package e2e_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ONSdigital/blaise-cawi-portal/fakeupstreams"
+	"github.com/ONSdigital/blaise-cawi-portal/webserver"
+	"github.com/gin-gonic/gin"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestE2e(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "E2e Suite")
+}
+
+var (
+	redis     *fakeRedis
+	upstreams *fakeupstreams.Server
+	upstream  *httptest.Server
+	portal    *httptest.Server
+)
+
+// The portal is booted once, through the same config loading and router
+// wiring as main, with the session database and every upstream faked in
+// process. It is served over TLS as its cookies are only sent over HTTPS.
+var _ = BeforeSuite(func() {
+	gin.SetMode(gin.TestMode)
+
+	var err error
+	redis, err = startFakeRedis()
+	Expect(err).ToNot(HaveOccurred())
+
+	fixtures, err := fakeupstreams.LoadFixtures("fixtures.json")
+	Expect(err).ToNot(HaveOccurred())
+	upstreams = fakeupstreams.NewServer(fixtures)
+	upstream = httptest.NewServer(upstreams)
+
+	for name, value := range map[string]string{
+		"SESSION_SECRET":    "0000000000000000000000000000000000000000000000000000000000000000",
+		"ENCRYPTION_SECRET": "00000000000000000000000000000000",
+		"JWT_SECRET":        "00000000000000000000000000000000",
+		"REDIS_SESSION_DB":  redis.Addr(),
+		"BUS_URL":           upstream.URL + fakeupstreams.BUS_PATH,
+		"BUS_AUTH":          "none",
+		"BLAISE_REST_API":   upstream.URL + fakeupstreams.REST_API_PATH,
+		"CATI_URL":          upstream.URL + fakeupstreams.CATI_PATH,
+	} {
+		os.Setenv(name, value)
+	}
+	config, err := webserver.LoadConfig()
+	Expect(err).ToNot(HaveOccurred())
+
+	// Templates and assets are found relative to the root of the repo
+	Expect(os.Chdir("..")).To(Succeed())
+	server := &webserver.Server{Config: config}
+	portal = httptest.NewTLSServer(server.SetupRouter())
+})
+
+var _ = AfterSuite(func() {
+	portal.Close()
+	upstream.Close()
+	redis.Close()
+})
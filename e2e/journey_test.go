@@ -0,0 +1,200 @@
+package e2e_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+	"github.com/ONSdigital/blaise-cawi-portal/blaise"
+	"github.com/golang-jwt/jwt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var csrfTokenPattern = regexp.MustCompile(`name="_csrf" value="([^"]+)"`)
+
+// respondent is a browser, keeping its cookies between requests and
+// leaving redirects for the journey to follow
+type respondent struct {
+	client *http.Client
+}
+
+func newRespondent() *respondent {
+	jar, err := cookiejar.New(nil)
+	Expect(err).ToNot(HaveOccurred())
+	client := portal.Client()
+	client.Jar = jar
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &respondent{client: client}
+}
+
+func (respondent *respondent) do(req *http.Request) (*http.Response, string) {
+	resp, err := respondent.client.Do(req)
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	Expect(err).ToNot(HaveOccurred())
+	return resp, string(body)
+}
+
+func (respondent *respondent) get(path string) (*http.Response, string) {
+	req, err := http.NewRequest("GET", portal.URL+path, nil)
+	Expect(err).ToNot(HaveOccurred())
+	return respondent.do(req)
+}
+
+func (respondent *respondent) postForm(path string, form url.Values) (*http.Response, string) {
+	req, err := http.NewRequest("POST", portal.URL+path, strings.NewReader(form.Encode()))
+	Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return respondent.do(req)
+}
+
+func (respondent *respondent) postJSON(path, body string) (*http.Response, string) {
+	req, err := http.NewRequest("POST", portal.URL+path, strings.NewReader(body))
+	Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("Content-Type", "application/json")
+	return respondent.do(req)
+}
+
+// storedJWTExpiry is when the JWT in the only saved user session for a case
+// expires
+func storedJWTExpiry(caseID string) time.Time {
+	var expiries []time.Time
+	for _, values := range redis.SessionValues() {
+		token, found := values[authenticate.JWT_TOKEN_KEY].(string)
+		if !found || token == "" {
+			continue
+		}
+		claims := &authenticate.UACClaims{}
+		_, _, err := new(jwt.Parser).ParseUnverified(token, claims)
+		Expect(err).ToNot(HaveOccurred())
+		if claims.UacInfo.CaseID == caseID {
+			expiries = append(expiries, time.Unix(claims.ExpiresAt, 0))
+		}
+	}
+	Expect(expiries).To(HaveLen(1))
+	return expiries[0]
+}
+
+// passTime moves the clocks that session tokens and the session database
+// expire by on, as if the respondent had left the portal alone that long
+func passTime(duration time.Duration) {
+	jwt.TimeFunc = func() time.Time {
+		return time.Now().Add(duration)
+	}
+	redis.Advance(duration)
+}
+
+func resetTime() {
+	jwt.TimeFunc = time.Now
+	redis.Reset()
+}
+
+type journeyText struct {
+	login     string
+	timedOut  []string
+	loggedOut string
+}
+
+var _ = Describe("Respondent journey", func() {
+	DescribeTable("from signing in to signing out, and timing out",
+		func(lang, uac, caseID string, text journeyText) {
+			respondent := newRespondent()
+
+			By("signing in")
+			resp, body := respondent.get("/auth/login?lang=" + lang)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("X-Frame-Options")).To(Equal("DENY"))
+			Expect(resp.Header.Get("Content-Security-Policy")).ToNot(BeEmpty())
+			Expect(body).To(ContainSubstring(`<html lang="` + lang + `">`))
+			Expect(body).To(ContainSubstring(text.login))
+			csrfToken := csrfTokenPattern.FindStringSubmatch(body)
+			Expect(csrfToken).To(HaveLen(2))
+
+			resp, _ = respondent.postForm("/auth/login", url.Values{"_csrf": {csrfToken[1]}, "uac": {uac}})
+			Expect(resp.StatusCode).To(Equal(http.StatusFound))
+			Expect(resp.Header.Get("Location")).To(Equal("/dst2106a/"))
+
+			By("opening the case in CATI")
+			resp, body = respondent.get("/dst2106a/")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(ContainSubstring(`<html lang="` + lang + `">`))
+			Expect(body).To(ContainSubstring(caseID))
+			Expect(body).To(ContainSubstring(`<script src="/assets/js/check-session.js"></script></body>`))
+
+			By("starting the interview, which refreshes the session")
+			expiry := storedJWTExpiry(caseID)
+			time.Sleep(1100 * time.Millisecond)
+			resp, body = respondent.postJSON("/dst2106a/api/application/start_interview",
+				`{"RuntimeParameters": {"KeyValue": "`+caseID+`", "Mode": "CAWI"}}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(ContainSubstring(`"started":true`))
+			Expect(upstreams.Interviews()).To(ContainElement(blaise.StartInterview{
+				RuntimeParameters: blaise.LaunchBlaise{KeyValue: caseID, Mode: "CAWI"},
+			}))
+			Expect(storedJWTExpiry(caseID)).To(BeTemporally(">", expiry))
+
+			resp, _ = respondent.get("/auth/logged-in")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			By("not starting someone else's interview")
+			resp, _ = respondent.postJSON("/dst2106a/api/application/start_interview",
+				`{"RuntimeParameters": {"KeyValue": "999999", "Mode": "CAWI"}}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+
+			By("signing out")
+			resp, body = respondent.get("/dst2106a/logout")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(ContainSubstring(text.loggedOut))
+
+			resp, _ = respondent.get("/auth/logged-in")
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			resp, body = respondent.get("/dst2106a/")
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(body).To(ContainSubstring(text.login))
+
+			By("signing in again and leaving the session until it times out")
+			csrfToken = csrfTokenPattern.FindStringSubmatch(body)
+			Expect(csrfToken).To(HaveLen(2))
+			resp, _ = respondent.postForm("/auth/login", url.Values{"_csrf": {csrfToken[1]}, "uac": {uac}})
+			Expect(resp.StatusCode).To(Equal(http.StatusFound))
+			resp, _ = respondent.get("/dst2106a/")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			passTime(16 * time.Minute)
+			defer resetTime()
+
+			resp, body = respondent.get("/dst2106a/")
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(body).To(ContainSubstring(text.login))
+			resp, _ = respondent.get("/auth/logged-in")
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+
+			resp, body = respondent.get("/auth/timed-out")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			for _, timedOut := range text.timedOut {
+				Expect(body).To(ContainSubstring(timedOut))
+			}
+		},
+		Entry("in English", "en", "1000 0000 0001", "100001", journeyText{
+			login:     "Start study",
+			timedOut:  []string{"inactive for 15 minutes", "Your answers have been saved."},
+			loggedOut: "Your progress has been saved",
+		}),
+		Entry("in Welsh", "cy", "abcd-efgh-jkmn-pqrt", "100002", journeyText{
+			login:     "Dechrau'r astudiaeth",
+			timedOut:  []string{"anweithgar am 15 munud", "Mae eich atebion wedi cael eu cadw."},
+			loggedOut: "Mae eich atebion wedi cael eu cadw.",
+		}),
+	)
+})
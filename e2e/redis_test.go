@@ -0,0 +1,298 @@
+package e2e_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fakeRedisItem struct {
+	value   string
+	expires time.Time
+}
+
+// fakeRedis is a miniredis-style, in-process stand in for the session
+// database. It speaks just enough of the Redis protocol for the redis
+// session store and kvstore.RedisStore. There is no Lua, so the scripts
+// kvstore.RedisStore runs are told apart by the commands they call.
+type fakeRedis struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	items    map[string]fakeRedisItem
+	skew     time.Duration
+}
+
+func startFakeRedis() (*fakeRedis, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	redis := &fakeRedis{listener: listener, items: map[string]fakeRedisItem{}}
+	go redis.serve()
+	return redis, nil
+}
+
+func (redis *fakeRedis) Addr() string {
+	return redis.listener.Addr().String()
+}
+
+func (redis *fakeRedis) Close() error {
+	return redis.listener.Close()
+}
+
+// Advance moves the clock keys expire by on, as if that much time had passed
+func (redis *fakeRedis) Advance(duration time.Duration) {
+	redis.mutex.Lock()
+	defer redis.mutex.Unlock()
+	redis.skew += duration
+}
+
+// Reset puts the clock keys expire by back to the real time
+func (redis *fakeRedis) Reset() {
+	redis.mutex.Lock()
+	defer redis.mutex.Unlock()
+	redis.skew = 0
+}
+
+func (redis *fakeRedis) now() time.Time {
+	return time.Now().Add(redis.skew)
+}
+
+// SessionValues decodes every session the session store has saved
+func (redis *fakeRedis) SessionValues() []map[interface{}]interface{} {
+	redis.mutex.Lock()
+	defer redis.mutex.Unlock()
+	var sessions []map[interface{}]interface{}
+	for key := range redis.items {
+		item, found := redis.get(key)
+		if !found || !strings.HasPrefix(key, "session_") {
+			continue
+		}
+		var values map[interface{}]interface{}
+		if err := gob.NewDecoder(bytes.NewBufferString(item.value)).Decode(&values); err == nil {
+			sessions = append(sessions, values)
+		}
+	}
+	return sessions
+}
+
+func (redis *fakeRedis) serve() {
+	for {
+		conn, err := redis.listener.Accept()
+		if err != nil {
+			return
+		}
+		go redis.handle(conn)
+	}
+}
+
+func (redis *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(redis.do(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		header, err := readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimPrefix(header, "$"))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:length])
+	}
+	return args, nil
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (redis *fakeRedis) do(args []string) []byte {
+	if len(args) == 0 {
+		return respError("empty command")
+	}
+	redis.mutex.Lock()
+	defer redis.mutex.Unlock()
+
+	switch command := strings.ToUpper(args[0]); {
+	case command == "PING":
+		return []byte("+PONG\r\n")
+	case command == "SELECT" || command == "AUTH":
+		return []byte("+OK\r\n")
+	case command == "GET" && len(args) == 2:
+		item, found := redis.get(args[1])
+		if !found {
+			return []byte("$-1\r\n")
+		}
+		return respBulk(item.value)
+	case command == "SET" && len(args) >= 3:
+		return redis.set(args[1], args[2], args[3:])
+	case command == "SETEX" && len(args) == 4:
+		seconds, err := strconv.Atoi(args[2])
+		if err != nil {
+			return respError(err.Error())
+		}
+		redis.items[args[1]] = fakeRedisItem{value: args[3], expires: redis.now().Add(time.Duration(seconds) * time.Second)}
+		return []byte("+OK\r\n")
+	case command == "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, found := redis.get(key); found {
+				delete(redis.items, key)
+				deleted++
+			}
+		}
+		return respInt(int64(deleted))
+	case command == "INCR" && len(args) == 2:
+		item, _ := redis.get(args[1])
+		count, _ := strconv.ParseInt(item.value, 10, 64)
+		count++
+		item.value = strconv.FormatInt(count, 10)
+		redis.items[args[1]] = item
+		return respInt(count)
+	case command == "PEXPIRE" && len(args) == 3:
+		item, found := redis.get(args[1])
+		if !found {
+			return respInt(0)
+		}
+		milliseconds, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return respError(err.Error())
+		}
+		item.expires = redis.now().Add(time.Duration(milliseconds) * time.Millisecond)
+		redis.items[args[1]] = item
+		return respInt(1)
+	case command == "EVALSHA":
+		return []byte("-NOSCRIPT No matching script\r\n")
+	case command == "EVAL" && len(args) >= 4:
+		return redis.eval(args[1], args[3:])
+	case command == "PTTL" && len(args) == 2:
+		item, found := redis.get(args[1])
+		if !found {
+			return respInt(-2)
+		}
+		if item.expires.IsZero() {
+			return respInt(-1)
+		}
+		return respInt(item.expires.Sub(redis.now()).Milliseconds())
+	}
+	return respError(fmt.Sprintf("unsupported command %q", strings.Join(args, " ")))
+}
+
+// eval runs the one-key scripts kvstore.RedisStore sends
+func (redis *fakeRedis) eval(script string, args []string) []byte {
+	key := args[0]
+	switch {
+	case strings.Contains(script, `redis.call("INCR", KEYS[1])`) && len(args) == 2:
+		item, found := redis.get(key)
+		count, _ := strconv.ParseInt(item.value, 10, 64)
+		count++
+		item.value = strconv.FormatInt(count, 10)
+		if milliseconds, _ := strconv.ParseInt(args[1], 10, 64); !found && milliseconds > 0 {
+			item.expires = redis.now().Add(time.Duration(milliseconds) * time.Millisecond)
+		}
+		redis.items[key] = item
+		return respInt(count)
+	case strings.Contains(script, `redis.call("GET", KEYS[1]) ~= ARGV[1]`) && len(args) == 3:
+		item, found := redis.get(key)
+		if !found || item.value != args[1] {
+			return respInt(0)
+		}
+		item.expires = time.Time{}
+		if milliseconds, _ := strconv.ParseInt(args[2], 10, 64); milliseconds > 0 {
+			item.expires = redis.now().Add(time.Duration(milliseconds) * time.Millisecond)
+		}
+		redis.items[key] = item
+		return respInt(1)
+	}
+	return respError("unsupported script")
+}
+
+func (redis *fakeRedis) set(key, value string, options []string) []byte {
+	item := fakeRedisItem{value: value}
+	onlyIfNew := false
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(options[i]) {
+		case "NX":
+			onlyIfNew = true
+		case "PX", "EX":
+			if i+1 == len(options) {
+				return respError("syntax error")
+			}
+			ttl, err := strconv.ParseInt(options[i+1], 10, 64)
+			if err != nil {
+				return respError(err.Error())
+			}
+			unit := time.Millisecond
+			if strings.ToUpper(options[i]) == "EX" {
+				unit = time.Second
+			}
+			item.expires = redis.now().Add(time.Duration(ttl) * unit)
+			i++
+		default:
+			return respError("syntax error")
+		}
+	}
+	if _, found := redis.get(key); found && onlyIfNew {
+		return []byte("$-1\r\n")
+	}
+	redis.items[key] = item
+	return []byte("+OK\r\n")
+}
+
+func (redis *fakeRedis) get(key string) (fakeRedisItem, bool) {
+	item, found := redis.items[key]
+	if found && !item.expires.IsZero() && !redis.now().Before(item.expires) {
+		delete(redis.items, key)
+		return fakeRedisItem{}, false
+	}
+	return item, found
+}
+
+func respBulk(value string) []byte {
+	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(value), value))
+}
+
+func respInt(value int64) []byte {
+	return []byte(fmt.Sprintf(":%d\r\n", value))
+}
+
+func respError(message string) []byte {
+	return []byte(fmt.Sprintf("-ERR %s\r\n", message))
+}
@@ -0,0 +1,248 @@
+// Package fakeupstreams stands in for BUS, the Blaise REST API and CATI, so
+// the portal can be run end to end without GCP. All three are served by one
+// handler, each under its own path, from a fixtures file of UACs and
+// instruments.
+package fakeupstreams
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/blaise-cawi-portal/blaise"
+	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
+	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+	"github.com/gin-gonic/gin"
+)
+
+// Paths each upstream is served under, to be added to the server's address
+// for BusUrl, BlaiseRestApi and CatiUrl
+const (
+	BUS_PATH      = "/bus"
+	REST_API_PATH = "/rest"
+	CATI_PATH     = "/cati"
+)
+
+// Fixtures are the UACs BUS knows and the instruments installed in Blaise
+type Fixtures struct {
+	Uacs        map[string]busapi.UacInfo `json:"uacs"`
+	Instruments map[string]Instrument     `json:"instruments"`
+}
+
+// Instrument is an instrument installed on Serverpark, or on any server
+// park if empty, with the outcome of each of its cases
+type Instrument struct {
+	Serverpark string                           `json:"serverpark"`
+	Settings   blaiserestapi.InstrumentSettings `json:"settings"`
+	Cases      map[string]int                   `json:"cases"`
+}
+
+// ParseFixtures parses fixtures such as:
+//
+//	{
+//	  "uacs": {"123456789012": {"instrument_name": "dst2106a", "case_id": "100001"}},
+//	  "instruments": {
+//	    "dst2106a": {
+//	      "settings": [{"type": "StrictInterviewing", "sessionTimeout": 15}],
+//	      "cases": {"100001": 0}
+//	    }
+//	  }
+//	}
+func ParseFixtures(data []byte) (*Fixtures, error) {
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, err
+	}
+	for uac, uacInfo := range fixtures.Uacs {
+		instrument, found := fixtures.Instruments[uacInfo.InstrumentName]
+		if !found {
+			return nil, fmt.Errorf("UAC %s is for instrument %q, which is not installed", uac, uacInfo.InstrumentName)
+		}
+		if _, found := instrument.Cases[uacInfo.CaseID]; !found {
+			return nil, fmt.Errorf("UAC %s is for case %q, which is not in instrument %q", uac, uacInfo.CaseID, uacInfo.InstrumentName)
+		}
+	}
+	return &fixtures, nil
+}
+
+func LoadFixtures(fixturesFile string) (*Fixtures, error) {
+	fileContents, err := ioutil.ReadFile(fixturesFile)
+	if err != nil {
+		return nil, err
+	}
+	fixtures, err := ParseFixtures(fileContents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fixturesFile, err)
+	}
+	return fixtures, nil
+}
+
+// Server serves the fixtures and records each interview CATI is asked to
+// start, so tests can check what reached it
+type Server struct {
+	Fixtures *Fixtures
+
+	router     *gin.Engine
+	mutex      sync.Mutex
+	interviews []blaise.StartInterview
+}
+
+func NewServer(fixtures *Fixtures) *Server {
+	server := &Server{Fixtures: fixtures, router: gin.New()}
+	server.router.Use(gin.Recovery())
+	server.router.POST(BUS_PATH+"/uacs/uac", server.uacInfo)
+	restApi := server.router.Group(REST_API_PATH + "/api/v2/serverparks/:serverpark/questionnaires/:instrumentName")
+	{
+		restApi.GET("/settings", server.instrumentSettings)
+		restApi.GET("/cases/:caseID/status", server.caseStatus)
+	}
+	cati := server.router.Group(CATI_PATH + "/:instrumentName")
+	{
+		cati.POST("/default.aspx", server.launchCase)
+		cati.POST("/api/application/start_interview", server.startInterview)
+	}
+	return server
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.router.ServeHTTP(w, r)
+}
+
+// Use adds middleware, such as a request logger, in front of every upstream
+func (server *Server) Use(middleware ...gin.HandlerFunc) {
+	server.router.Use(middleware...)
+}
+
+// Interviews returns the interviews started so far, oldest first
+func (server *Server) Interviews() []blaise.StartInterview {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]blaise.StartInterview(nil), server.interviews...)
+}
+
+func (server *Server) uacInfo(context *gin.Context) {
+	var uacRequest busapi.UACRequest
+	if err := context.ShouldBindJSON(&uacRequest); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uacInfo, found := server.Fixtures.Uacs[uacRequest.UAC]
+	if !found {
+		context.JSON(http.StatusNotFound, gin.H{"error": "UAC not found"})
+		return
+	}
+	context.JSON(http.StatusOK, uacInfo)
+}
+
+func (server *Server) instrumentSettings(context *gin.Context) {
+	instrument, found := server.instrument(context.Param("serverpark"), context.Param("instrumentName"))
+	if !found {
+		context.JSON(http.StatusNotFound, gin.H{"error": "Questionnaire not found"})
+		return
+	}
+	settings := instrument.Settings
+	if settings == nil {
+		settings = blaiserestapi.InstrumentSettings{}
+	}
+	context.JSON(http.StatusOK, settings)
+}
+
+func (server *Server) caseStatus(context *gin.Context) {
+	instrument, found := server.instrument(context.Param("serverpark"), context.Param("instrumentName"))
+	if !found {
+		context.JSON(http.StatusNotFound, gin.H{"error": "Questionnaire not found"})
+		return
+	}
+	caseID := context.Param("caseID")
+	outcome, found := instrument.Cases[caseID]
+	if !found {
+		context.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+		return
+	}
+	context.JSON(http.StatusOK, blaiserestapi.CaseStatus{PrimaryKey: caseID, Outcome: outcome})
+}
+
+func (server *Server) launchCase(context *gin.Context) {
+	instrumentName := context.Param("instrumentName")
+	caseID := context.PostForm("KeyValue")
+	if !server.hasCase(instrumentName, caseID) || context.PostForm("Mode") != "CAWI" {
+		context.String(http.StatusNotFound, "Case not found")
+		return
+	}
+	context.Status(http.StatusOK)
+	context.Header("Content-Type", "text/html; charset=utf-8")
+	launchPage.Execute(context.Writer, gin.H{
+		"instrumentName": instrumentName,
+		"caseID":         caseID,
+		"welsh":          context.PostForm("Language") == "WLS",
+	})
+}
+
+func (server *Server) startInterview(context *gin.Context) {
+	instrumentName := context.Param("instrumentName")
+	var startInterview blaise.StartInterview
+	if err := context.ShouldBindJSON(&startInterview); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !server.hasCase(instrumentName, startInterview.RuntimeParameters.KeyValue) {
+		context.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+		return
+	}
+	server.mutex.Lock()
+	server.interviews = append(server.interviews, startInterview)
+	server.mutex.Unlock()
+	context.JSON(http.StatusOK, gin.H{
+		"instrumentName": instrumentName,
+		"caseId":         startInterview.RuntimeParameters.KeyValue,
+		"started":        true,
+	})
+}
+
+func (server *Server) instrument(serverpark, instrumentName string) (Instrument, bool) {
+	for name, instrument := range server.Fixtures.Instruments {
+		if !strings.EqualFold(name, instrumentName) {
+			continue
+		}
+		if instrument.Serverpark != "" && instrument.Serverpark != serverpark {
+			return Instrument{}, false
+		}
+		return instrument, true
+	}
+	return Instrument{}, false
+}
+
+func (server *Server) hasCase(instrumentName, caseID string) bool {
+	for name, instrument := range server.Fixtures.Instruments {
+		if strings.EqualFold(name, instrumentName) {
+			_, found := instrument.Cases[caseID]
+			return found
+		}
+	}
+	return false
+}
+
+var launchPage = template.Must(template.New("default.aspx").Parse(`<!doctype html>
+<html lang="{{if .welsh}}cy{{else}}en{{end}}">
+<head><title>{{.instrumentName}}</title></head>
+<body>
+<h1>{{.instrumentName}}</h1>
+<p id="case">{{if .welsh}}Achos{{else}}Case{{end}} {{.caseID}}</p>
+<button role="button" id="start-interview">{{if .welsh}}Dechrau{{else}}Start{{end}}</button>
+<p id="status"></p>
+<script>
+document.getElementById("start-interview").addEventListener("click", function() {
+  var xmlHttp = new XMLHttpRequest();
+  xmlHttp.open("POST", "api/application/start_interview", false);
+  xmlHttp.setRequestHeader("Content-Type", "application/json");
+  xmlHttp.send(JSON.stringify({RuntimeParameters: {KeyValue: "{{.caseID}}", Mode: "CAWI"}}));
+  document.getElementById("status").innerText = xmlHttp.status === 200 ? "Started" : "Failed: " + xmlHttp.status;
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,13 @@
+package fakeupstreams_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFakeupstreams(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fakeupstreams Suite")
+}
@@ -0,0 +1,137 @@
+package fakeupstreams_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/blaise-cawi-portal/blaise"
+	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
+	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+	"github.com/ONSdigital/blaise-cawi-portal/fakeupstreams"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fake upstreams", func() {
+	var (
+		upstreams *fakeupstreams.Server
+		server    *httptest.Server
+	)
+
+	BeforeEach(func() {
+		fixtures, err := fakeupstreams.LoadFixtures("../cmd/fakeupstreams/fixtures.json")
+		Expect(err).ToNot(HaveOccurred())
+		upstreams = fakeupstreams.NewServer(fixtures)
+		server = httptest.NewServer(upstreams)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("BUS", func() {
+		var busApi *busapi.BusApi
+
+		BeforeEach(func() {
+			busApi = &busapi.BusApi{BaseUrl: server.URL + fakeupstreams.BUS_PATH, Client: server.Client()}
+		})
+
+		It("looks up known UACs", func() {
+			uacInfo, err := busApi.GetUacInfo(context.Background(), "100000000001")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uacInfo).To(Equal(busapi.UacInfo{InstrumentName: "dst2106a", CaseID: "100001"}))
+		})
+
+		It("does not find unknown UACs", func() {
+			_, err := busApi.GetUacInfo(context.Background(), "999999999999")
+			var notFoundError *upstream.NotFoundError
+			Expect(errors.As(err, &notFoundError)).To(BeTrue())
+		})
+	})
+
+	Describe("the Blaise REST API", func() {
+		var blaiseRestApi *blaiserestapi.BlaiseRestApi
+
+		BeforeEach(func() {
+			blaiseRestApi = &blaiserestapi.BlaiseRestApi{
+				BaseUrl:    server.URL + fakeupstreams.REST_API_PATH,
+				Serverpark: "gusty",
+				Client:     server.Client(),
+			}
+		})
+
+		It("returns instrument settings", func() {
+			instrumentSettings, err := blaiseRestApi.GetInstrumentSettings(context.Background(), "dst2106a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instrumentSettings.StrictInterviewing().SessionTimeout).To(Equal(15))
+		})
+
+		It("does not find instruments on another server park", func() {
+			blaiseRestApi.Serverpark = "windy"
+			_, err := blaiseRestApi.GetInstrumentSettings(context.Background(), "dst2106a")
+			Expect(err).To(Equal(blaiserestapi.InstrumentNotFoundError))
+		})
+
+		It("returns case statuses", func() {
+			caseStatus, err := blaiseRestApi.GetCaseStatus(context.Background(), "dst2106a", "100003")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(caseStatus.Completed()).To(BeTrue())
+		})
+
+		It("does not find unknown cases", func() {
+			_, err := blaiseRestApi.GetCaseStatus(context.Background(), "dst2106a", "999999")
+			Expect(err).To(Equal(blaiserestapi.CaseNotFoundError))
+		})
+	})
+
+	Describe("CATI", func() {
+		It("launches a case", func() {
+			resp, err := http.PostForm(server.URL+fakeupstreams.CATI_PATH+"/dst2106a/default.aspx",
+				blaise.CasePayload("100001", true).Form())
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/html"))
+		})
+
+		It("does not launch unknown cases", func() {
+			resp, err := http.PostForm(server.URL+fakeupstreams.CATI_PATH+"/dst2106a/default.aspx",
+				url.Values{"KeyValue": {"999999"}, "Mode": {"CAWI"}})
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		})
+
+		It("starts and records interviews", func() {
+			resp, err := http.Post(server.URL+fakeupstreams.CATI_PATH+"/dst2106a/api/application/start_interview",
+				"application/json", strings.NewReader(`{"RuntimeParameters": {"KeyValue": "100001", "Mode": "CAWI"}}`))
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(upstreams.Interviews()).To(Equal([]blaise.StartInterview{
+				{RuntimeParameters: blaise.LaunchBlaise{KeyValue: "100001", Mode: "CAWI"}},
+			}))
+		})
+	})
+
+	Describe("ParseFixtures", func() {
+		It("rejects UACs for instruments that are not installed", func() {
+			_, err := fakeupstreams.ParseFixtures([]byte(`{"uacs": {"100000000001": {"instrument_name": "lms2101a", "case_id": "1"}}}`))
+			Expect(err).To(MatchError(`UAC 100000000001 is for instrument "lms2101a", which is not installed`))
+		})
+
+		It("rejects UACs for cases not in the instrument", func() {
+			_, err := fakeupstreams.ParseFixtures([]byte(`{
+				"uacs": {"100000000001": {"instrument_name": "dst2106a", "case_id": "2"}},
+				"instruments": {"dst2106a": {"cases": {"1": 0}}}
+			}`))
+			Expect(err).To(MatchError(`UAC 100000000001 is for case "2", which is not in instrument "dst2106a"`))
+		})
+	})
+})
@@ -0,0 +1,156 @@
+package fieldperiod
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+)
+
+const (
+	OPEN     Status = "open"
+	NOT_OPEN Status = "not_open"
+	CLOSED   Status = "closed"
+
+	DATE_FORMAT = "2006-01-02"
+)
+
+// Location is where dates without a time are taken to start and end
+var Location = mustLoadLocation("Europe/London")
+
+var welshMonths = [...]string{
+	"Ionawr", "Chwefror", "Mawrth", "Ebrill", "Mai", "Mehefin",
+	"Gorffennaf", "Awst", "Medi", "Hydref", "Tachwedd", "Rhagfyr",
+}
+
+type Status string
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name FieldPeriodsInterface
+type FieldPeriodsInterface interface {
+	Check(string, time.Time) (Status, FieldPeriod)
+}
+
+// FieldPeriod is when an instrument is open for collection. Opens is the
+// first moment it is open and Closes the first moment it is not; either
+// may be zero to leave that end of the period unbounded.
+type FieldPeriod struct {
+	Opens  time.Time
+	Closes time.Time
+}
+
+func (fieldPeriod FieldPeriod) Status(now time.Time) Status {
+	if !fieldPeriod.Opens.IsZero() && now.Before(fieldPeriod.Opens) {
+		return NOT_OPEN
+	}
+	if !fieldPeriod.Closes.IsZero() && !now.Before(fieldPeriod.Closes) {
+		return CLOSED
+	}
+	return OPEN
+}
+
+// FieldPeriods holds the field period for each instrument pattern, an
+// instrument name or a prefix such as "dst21*". Instruments without a
+// field period are always open.
+type FieldPeriods map[string]FieldPeriod
+
+func (fieldPeriods FieldPeriods) Check(instrumentName string, now time.Time) (Status, FieldPeriod) {
+	patterns := make([]string, 0, len(fieldPeriods))
+	for pattern := range fieldPeriods {
+		patterns = append(patterns, pattern)
+	}
+	pattern, found := utils.MatchInstrument(instrumentName, patterns)
+	if !found {
+		return OPEN, FieldPeriod{}
+	}
+	fieldPeriod := fieldPeriods[pattern]
+	return fieldPeriod.Status(now), fieldPeriod
+}
+
+type fieldPeriodEntry struct {
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+// ParseFieldPeriods parses a JSON object of instrument patterns to their
+// field period, for example:
+//
+//	{"dst21*": {"opens": "2021-06-01", "closes": "2021-06-30"}}
+//
+// Dates are either a day, which opens at the start of that day and closes at
+// the end of it in Location, or an RFC 3339 timestamp.
+func ParseFieldPeriods(data []byte) (FieldPeriods, error) {
+	var entries map[string]fieldPeriodEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	fieldPeriods := FieldPeriods{}
+	for pattern, entry := range entries {
+		opens, err := parseTime(entry.Opens, false)
+		if err != nil {
+			return nil, fmt.Errorf("field period for %q: %w", pattern, err)
+		}
+		closes, err := parseTime(entry.Closes, true)
+		if err != nil {
+			return nil, fmt.Errorf("field period for %q: %w", pattern, err)
+		}
+		if !opens.IsZero() && !closes.IsZero() && !closes.After(opens) {
+			return nil, fmt.Errorf("field period for %q closes before it opens", pattern)
+		}
+		fieldPeriods[pattern] = FieldPeriod{Opens: opens, Closes: closes}
+	}
+	return fieldPeriods, nil
+}
+
+func LoadFieldPeriods(fieldPeriodsFile string) (FieldPeriods, error) {
+	if fieldPeriodsFile == "" {
+		return FieldPeriods{}, nil
+	}
+	fileContents, err := ioutil.ReadFile(fieldPeriodsFile)
+	if err != nil {
+		return nil, err
+	}
+	fieldPeriods, err := ParseFieldPeriods(fileContents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fieldPeriodsFile, err)
+	}
+	return fieldPeriods, nil
+}
+
+// FormatDate formats a date for respondents, such as "5 July 2021"
+func FormatDate(date time.Time, welsh bool) string {
+	date = date.In(Location)
+	if welsh {
+		return fmt.Sprintf("%d %s %d", date.Day(), welshMonths[date.Month()-1], date.Year())
+	}
+	return date.Format("2 January 2006")
+}
+
+func parseTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.ParseInLocation(DATE_FORMAT, value, Location); err == nil {
+		if endOfDay {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (%s) or an RFC 3339 timestamp", value, DATE_FORMAT)
+	}
+	return parsed, nil
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
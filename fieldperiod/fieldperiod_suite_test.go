@@ -0,0 +1,13 @@
+package fieldperiod_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFieldperiod(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fieldperiod Suite")
+}
@@ -0,0 +1,77 @@
+package fieldperiod_test
+
+import (
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/fieldperiod"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FieldPeriods", func() {
+	var (
+		fieldPeriods fieldperiod.FieldPeriods
+		london       = fieldperiod.Location
+	)
+
+	BeforeEach(func() {
+		var err error
+		fieldPeriods, err = fieldperiod.ParseFieldPeriods([]byte(`{
+			"dst21*": {"opens": "2021-06-01", "closes": "2021-06-30"},
+			"dst2106b": {"opens": "2021-07-01T09:00:00+01:00"},
+			"lms*": {"closes": "2021-12-31"}
+		}`))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	DescribeTable("checks whether an instrument is open",
+		func(instrumentName string, now time.Time, expectedStatus fieldperiod.Status) {
+			status, _ := fieldPeriods.Check(instrumentName, now)
+			Expect(status).To(Equal(expectedStatus))
+		},
+		Entry("before a prefix opens", "dst2106a", time.Date(2021, 5, 31, 23, 59, 0, 0, london), fieldperiod.NOT_OPEN),
+		Entry("at the start of the opening day", "dst2106a", time.Date(2021, 6, 1, 0, 0, 0, 0, london), fieldperiod.OPEN),
+		Entry("on the closing day", "dst2106a", time.Date(2021, 6, 30, 23, 59, 0, 0, london), fieldperiod.OPEN),
+		Entry("after the closing day", "dst2106a", time.Date(2021, 7, 1, 0, 0, 0, 0, london), fieldperiod.CLOSED),
+		Entry("an exact name over a prefix", "dst2106b", time.Date(2021, 6, 15, 0, 0, 0, 0, london), fieldperiod.NOT_OPEN),
+		Entry("from an opening time", "dst2106b", time.Date(2021, 7, 1, 9, 0, 0, 0, london), fieldperiod.OPEN),
+		Entry("with no opening date", "lms2101a", time.Date(2000, 1, 1, 0, 0, 0, 0, london), fieldperiod.OPEN),
+		Entry("without a field period", "opn2101a", time.Date(2021, 6, 15, 0, 0, 0, 0, london), fieldperiod.OPEN),
+	)
+
+	It("returns the field period that applies", func() {
+		_, fieldPeriod := fieldPeriods.Check("dst2106a", time.Now())
+		Expect(fieldPeriod.Opens).To(BeTemporally("==", time.Date(2021, 6, 1, 0, 0, 0, 0, london)))
+		Expect(fieldPeriod.Closes).To(BeTemporally("==", time.Date(2021, 7, 1, 0, 0, 0, 0, london)))
+	})
+
+	Describe("ParseFieldPeriods", func() {
+		It("rejects a field period that closes before it opens", func() {
+			_, err := fieldperiod.ParseFieldPeriods([]byte(`{"dst*": {"opens": "2021-06-30", "closes": "2021-06-01"}}`))
+			Expect(err).To(MatchError(`field period for "dst*" closes before it opens`))
+		})
+
+		It("rejects dates it cannot parse", func() {
+			_, err := fieldperiod.ParseFieldPeriods([]byte(`{"dst*": {"opens": "01/06/2021"}}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("LoadFieldPeriods", func() {
+		It("returns no field periods without a file", func() {
+			fieldPeriods, err := fieldperiod.LoadFieldPeriods("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fieldPeriods).To(BeEmpty())
+		})
+	})
+
+	DescribeTable("FormatDate",
+		func(welsh bool, expected string) {
+			Expect(fieldperiod.FormatDate(time.Date(2021, 7, 5, 23, 30, 0, 0, time.UTC), welsh)).To(Equal(expected))
+		},
+		Entry("in English", false, "6 July 2021"),
+		Entry("in Welsh", true, "6 Gorffennaf 2021"),
+	)
+})
@@ -0,0 +1,13 @@
+package kvstore_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestKvstore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Kvstore Suite")
+}
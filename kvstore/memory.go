@@ -0,0 +1,140 @@
+package kvstore
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	value   string
+	expires time.Time
+}
+
+func (item memoryItem) expired(now time.Time) bool {
+	return !item.expires.IsZero() && !now.Before(item.expires)
+}
+
+// MemoryStore is an in-process stand in for RedisStore, used in DevMode
+// where there is no session database
+type MemoryStore struct {
+	mutex sync.Mutex
+	items map[string]memoryItem
+	Now   func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: map[string]memoryItem{},
+		Now:   time.Now,
+	}
+}
+
+func (memoryStore *MemoryStore) Incr(key string, ttl time.Duration) (int64, error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	now := memoryStore.Now()
+	item, found := memoryStore.get(key, now)
+	if !found {
+		item = memoryItem{value: "0"}
+		if ttl > 0 {
+			item.expires = now.Add(ttl)
+		}
+	}
+	count, err := strconv.ParseInt(item.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	count++
+	item.value = strconv.FormatInt(count, 10)
+	memoryStore.items[key] = item
+	return count, nil
+}
+
+func (memoryStore *MemoryStore) Get(key string) (string, error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	item, _ := memoryStore.get(key, memoryStore.Now())
+	return item.value, nil
+}
+
+func (memoryStore *MemoryStore) Set(key, value string, ttl time.Duration) error {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expires = memoryStore.Now().Add(ttl)
+	}
+	memoryStore.items[key] = item
+	return nil
+}
+
+func (memoryStore *MemoryStore) SetNX(key, value string, ttl time.Duration) (bool, error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	now := memoryStore.Now()
+	if _, found := memoryStore.get(key, now); found {
+		return false, nil
+	}
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expires = now.Add(ttl)
+	}
+	memoryStore.items[key] = item
+	return true, nil
+}
+
+func (memoryStore *MemoryStore) ExpireIfEqual(key, value string, ttl time.Duration) (bool, error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	now := memoryStore.Now()
+	item, found := memoryStore.get(key, now)
+	if !found || item.value != value {
+		return false, nil
+	}
+	item.expires = time.Time{}
+	if ttl > 0 {
+		item.expires = now.Add(ttl)
+	}
+	memoryStore.items[key] = item
+	return true, nil
+}
+
+func (memoryStore *MemoryStore) TTL(key string) (time.Duration, error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	now := memoryStore.Now()
+	item, found := memoryStore.get(key, now)
+	if !found || item.expires.IsZero() {
+		return 0, nil
+	}
+	return item.expires.Sub(now), nil
+}
+
+func (memoryStore *MemoryStore) Del(keys ...string) error {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(memoryStore.items, key)
+	}
+	return nil
+}
+
+func (memoryStore *MemoryStore) get(key string, now time.Time) (memoryItem, bool) {
+	item, found := memoryStore.items[key]
+	if !found {
+		return memoryItem{}, false
+	}
+	if item.expired(now) {
+		delete(memoryStore.items, key)
+		return memoryItem{}, false
+	}
+	return item, true
+}
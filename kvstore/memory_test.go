@@ -0,0 +1,119 @@
+package kvstore_test
+
+import (
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MemoryStore", func() {
+	var (
+		memoryStore *kvstore.MemoryStore
+		now         time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2022, 1, 1, 9, 0, 0, 0, time.UTC)
+		memoryStore = kvstore.NewMemoryStore()
+		memoryStore.Now = func() time.Time { return now }
+	})
+
+	Describe("Incr", func() {
+		It("counts up from one", func() {
+			Expect(memoryStore.Incr("foo", time.Minute)).To(Equal(int64(1)))
+			Expect(memoryStore.Incr("foo", time.Minute)).To(Equal(int64(2)))
+		})
+
+		It("keeps the expiry from when the counter was created", func() {
+			memoryStore.Incr("foo", time.Minute)
+			now = now.Add(30 * time.Second)
+			memoryStore.Incr("foo", time.Minute)
+			Expect(memoryStore.TTL("foo")).To(Equal(30 * time.Second))
+		})
+
+		It("starts again once the counter has expired", func() {
+			memoryStore.Incr("foo", time.Minute)
+			memoryStore.Incr("foo", time.Minute)
+			now = now.Add(time.Minute)
+			Expect(memoryStore.Incr("foo", time.Minute)).To(Equal(int64(1)))
+		})
+	})
+
+	Describe("Set and TTL", func() {
+		It("returns the time left on a key", func() {
+			Expect(memoryStore.Set("foo", "bar", 5*time.Minute)).To(Succeed())
+			now = now.Add(time.Minute)
+			Expect(memoryStore.TTL("foo")).To(Equal(4 * time.Minute))
+		})
+
+		It("returns zero for a missing key", func() {
+			Expect(memoryStore.TTL("foo")).To(Equal(time.Duration(0)))
+		})
+
+		It("returns zero for an expired key", func() {
+			memoryStore.Set("foo", "bar", time.Minute)
+			now = now.Add(2 * time.Minute)
+			Expect(memoryStore.TTL("foo")).To(Equal(time.Duration(0)))
+		})
+	})
+
+	Describe("Get", func() {
+		It("returns the value of a key", func() {
+			memoryStore.Set("foo", "bar", time.Minute)
+			Expect(memoryStore.Get("foo")).To(Equal("bar"))
+		})
+
+		It("returns an empty string for a missing or expired key", func() {
+			memoryStore.Set("foo", "bar", time.Minute)
+			now = now.Add(time.Minute)
+			Expect(memoryStore.Get("foo")).To(BeEmpty())
+			Expect(memoryStore.Get("fizz")).To(BeEmpty())
+		})
+	})
+
+	Describe("SetNX", func() {
+		It("only sets a key that does not exist", func() {
+			Expect(memoryStore.SetNX("foo", "bar", time.Minute)).To(BeTrue())
+			Expect(memoryStore.SetNX("foo", "bar", time.Minute)).To(BeFalse())
+		})
+
+		It("sets a key again once it has expired", func() {
+			memoryStore.SetNX("foo", "bar", time.Minute)
+			now = now.Add(time.Minute)
+			Expect(memoryStore.SetNX("foo", "bar", time.Minute)).To(BeTrue())
+		})
+	})
+
+	Describe("ExpireIfEqual", func() {
+		It("resets the expiry of a key holding the value", func() {
+			memoryStore.Set("foo", "bar", time.Minute)
+			Expect(memoryStore.ExpireIfEqual("foo", "bar", time.Hour)).To(BeTrue())
+			Expect(memoryStore.TTL("foo")).To(Equal(time.Hour))
+		})
+
+		It("leaves a key holding another value alone", func() {
+			memoryStore.Set("foo", "fizz", time.Minute)
+			Expect(memoryStore.ExpireIfEqual("foo", "bar", time.Hour)).To(BeFalse())
+			Expect(memoryStore.Get("foo")).To(Equal("fizz"))
+			Expect(memoryStore.TTL("foo")).To(Equal(time.Minute))
+		})
+
+		It("does not bring back a missing key", func() {
+			Expect(memoryStore.ExpireIfEqual("foo", "", time.Hour)).To(BeFalse())
+			Expect(memoryStore.TTL("foo")).To(Equal(time.Duration(0)))
+		})
+	})
+
+	Describe("Del", func() {
+		It("removes keys", func() {
+			memoryStore.Set("foo", "bar", time.Minute)
+			memoryStore.Incr("fizz", time.Minute)
+			Expect(memoryStore.Del("foo", "fizz")).To(Succeed())
+			Expect(memoryStore.TTL("foo")).To(Equal(time.Duration(0)))
+			Expect(memoryStore.Incr("fizz", time.Minute)).To(Equal(int64(1)))
+		})
+	})
+})
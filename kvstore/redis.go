@@ -0,0 +1,145 @@
+package kvstore
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type RedisStore struct {
+	Pool *redis.Pool
+}
+
+func NewRedisStore(address string) *RedisStore {
+	return &RedisStore{
+		Pool: &redis.Pool{
+			MaxIdle:     10,
+			IdleTimeout: 240 * time.Second,
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", address)
+			},
+		},
+	}
+}
+
+// incrScript increments a counter and starts its expiry window in one step,
+// so a counter can never be left without an expiry. Counters found without
+// one, such as those left by an earlier failure, are given one too.
+var incrScript = redis.NewScript(1, `
+local count = redis.call("INCR", KEYS[1])
+local ttl = tonumber(ARGV[1])
+if ttl > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return count
+`)
+
+// Incr increments the counter at key, starting the expiry window when the
+// counter is first created
+func (redisStore *RedisStore) Incr(key string, ttl time.Duration) (int64, error) {
+	conn := redisStore.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(incrScript.Do(conn, key, ttl.Milliseconds()))
+}
+
+// Get returns the value at key, or an empty string if it does not exist
+func (redisStore *RedisStore) Get(key string) (string, error) {
+	conn := redisStore.Pool.Get()
+	defer conn.Close()
+
+	value, err := redis.String(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return value, err
+}
+
+func (redisStore *RedisStore) Set(key, value string, ttl time.Duration) error {
+	conn := redisStore.Pool.Get()
+	defer conn.Close()
+
+	var err error
+	if ttl > 0 {
+		_, err = conn.Do("SET", key, value, "PX", ttl.Milliseconds())
+	} else {
+		_, err = conn.Do("SET", key, value)
+	}
+	return err
+}
+
+// SetNX sets key only if it does not already exist, reporting whether it
+// was set
+func (redisStore *RedisStore) SetNX(key, value string, ttl time.Duration) (bool, error) {
+	conn := redisStore.Pool.Get()
+	defer conn.Close()
+
+	var (
+		reply interface{}
+		err   error
+	)
+	if ttl > 0 {
+		reply, err = conn.Do("SET", key, value, "PX", ttl.Milliseconds(), "NX")
+	} else {
+		reply, err = conn.Do("SET", key, value, "NX")
+	}
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// expireIfEqualScript resets a key's expiry only while it still holds the
+// expected value, so that a value written in the meantime is left alone
+var expireIfEqualScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+else
+	redis.call("PERSIST", KEYS[1])
+end
+return 1
+`)
+
+// ExpireIfEqual resets the expiry of key only if it holds value, reporting
+// whether it did
+func (redisStore *RedisStore) ExpireIfEqual(key, value string, ttl time.Duration) (bool, error) {
+	conn := redisStore.Pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(expireIfEqualScript.Do(conn, key, value, ttl.Milliseconds()))
+}
+
+// TTL returns the time left before key expires, or zero if the key does not
+// exist or has no expiry
+func (redisStore *RedisStore) TTL(key string) (time.Duration, error) {
+	conn := redisStore.Pool.Get()
+	defer conn.Close()
+
+	milliseconds, err := redis.Int64(conn.Do("PTTL", key))
+	if err != nil {
+		return 0, err
+	}
+	if milliseconds < 0 {
+		return 0, nil
+	}
+	return time.Duration(milliseconds) * time.Millisecond, nil
+}
+
+func (redisStore *RedisStore) Del(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	conn := redisStore.Pool.Get()
+	defer conn.Close()
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	_, err := conn.Do("DEL", args...)
+	return err
+}
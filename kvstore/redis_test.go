@@ -0,0 +1,76 @@
+package kvstore_test
+
+import (
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	"github.com/gomodule/redigo/redis"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeConn records the commands sent to Redis. It has no scripts loaded, so
+// EVALSHA is answered with NOSCRIPT and the script is sent in full.
+type fakeConn struct {
+	commands [][]interface{}
+}
+
+func (conn *fakeConn) Do(command string, args ...interface{}) (interface{}, error) {
+	if command == "" {
+		// The pool flushes connections as they are put back
+		return nil, nil
+	}
+	conn.commands = append(conn.commands, append([]interface{}{command}, args...))
+	if command == "EVALSHA" {
+		return nil, redis.Error("NOSCRIPT No matching script")
+	}
+	return int64(1), nil
+}
+
+func (conn *fakeConn) Close() error                      { return nil }
+func (conn *fakeConn) Err() error                        { return nil }
+func (conn *fakeConn) Send(string, ...interface{}) error { return nil }
+func (conn *fakeConn) Flush() error                      { return nil }
+func (conn *fakeConn) Receive() (interface{}, error)     { return nil, nil }
+
+var _ = Describe("RedisStore", func() {
+	var (
+		conn       *fakeConn
+		redisStore *kvstore.RedisStore
+	)
+
+	BeforeEach(func() {
+		conn = &fakeConn{}
+		redisStore = &kvstore.RedisStore{Pool: &redis.Pool{
+			Dial: func() (redis.Conn, error) { return conn, nil },
+		}}
+	})
+
+	Describe("Incr", func() {
+		It("increments and sets the expiry in one script", func() {
+			Expect(redisStore.Incr("foo", time.Minute)).To(Equal(int64(1)))
+
+			Expect(conn.commands).To(HaveLen(2))
+			Expect(conn.commands[0][0]).To(Equal("EVALSHA"))
+			eval := conn.commands[1]
+			Expect(eval[0]).To(Equal("EVAL"))
+			Expect(eval[1]).To(ContainSubstring(`redis.call("INCR", KEYS[1])`))
+			Expect(eval[1]).To(ContainSubstring(`redis.call("PEXPIRE", KEYS[1], ttl)`))
+			Expect(eval[2:]).To(Equal([]interface{}{1, "foo", int64(60000)}))
+		})
+	})
+
+	Describe("ExpireIfEqual", func() {
+		It("compares and sets the expiry in one script", func() {
+			Expect(redisStore.ExpireIfEqual("foo", "bar", time.Minute)).To(BeTrue())
+
+			Expect(conn.commands).To(HaveLen(2))
+			eval := conn.commands[1]
+			Expect(eval[0]).To(Equal("EVAL"))
+			Expect(eval[1]).To(ContainSubstring(`redis.call("GET", KEYS[1]) ~= ARGV[1]`))
+			Expect(eval[1]).To(ContainSubstring(`redis.call("PEXPIRE", KEYS[1], ttl)`))
+			Expect(eval[2:]).To(Equal([]interface{}{1, "foo", "bar", int64(60000)}))
+		})
+	})
+})
@@ -0,0 +1,15 @@
+package kvstore
+
+import "time"
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name StoreInterface
+type StoreInterface interface {
+	Incr(string, time.Duration) (int64, error)
+	Get(string) (string, error)
+	Set(string, string, time.Duration) error
+	SetNX(string, string, time.Duration) (bool, error)
+	ExpireIfEqual(string, string, time.Duration) (bool, error)
+	TTL(string) (time.Duration, error)
+	Del(...string) error
+}
@@ -0,0 +1,167 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+	"go.uber.org/zap"
+)
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name MaintenanceInterface
+type MaintenanceInterface interface {
+	Check(string) (bool, Window)
+}
+
+// Window is a period of maintenance. Until is when we expect to be back, if
+// we know.
+type Window struct {
+	Enabled bool       `json:"enabled"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// Settings turn maintenance on for everything, or for instruments by name or
+// prefix*, for example:
+//
+//	{"global": {"enabled": false}, "instruments": {"dst21*": {"enabled": true, "until": "2021-06-01T09:00:00+01:00"}}}
+type Settings struct {
+	Global      Window            `json:"global"`
+	Instruments map[string]Window `json:"instruments"`
+}
+
+// SETTINGS_KEY is where the settings are kept in the shared store, so that
+// every instance of the portal sees the same settings
+const SETTINGS_KEY = "maintenance_settings"
+
+// Maintenance holds the maintenance settings read from Store, which Reload
+// reads again whenever they have changed. Without any settings stored nothing
+// is under maintenance.
+type Maintenance struct {
+	Store  kvstore.StoreInterface
+	Logger *zap.Logger
+
+	mutex    sync.RWMutex
+	settings Settings
+	loaded   string
+}
+
+// NewMaintenance loads the settings in store. If they cannot be loaded,
+// nothing is under maintenance until they can be.
+func NewMaintenance(store kvstore.StoreInterface, logger *zap.Logger) *Maintenance {
+	maintenance := &Maintenance{Store: store, Logger: logger}
+	if err := maintenance.Reload(); err != nil {
+		logger.Error("Could not load maintenance settings", zap.Error(err))
+	}
+	return maintenance
+}
+
+// SaveSettings stores new settings for every instance to pick up when it
+// next reloads
+func SaveSettings(store kvstore.StoreInterface, settings Settings) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return store.Set(SETTINGS_KEY, string(settingsJSON), 0)
+}
+
+// LoadSettings reads the stored settings, which are empty if none have been
+// saved
+func LoadSettings(store kvstore.StoreInterface) (Settings, error) {
+	settingsJSON, err := store.Get(SETTINGS_KEY)
+	if err != nil {
+		return Settings{}, err
+	}
+	return parseSettings(settingsJSON)
+}
+
+func parseSettings(settingsJSON string) (Settings, error) {
+	var settings Settings
+	if settingsJSON == "" {
+		return settings, nil
+	}
+	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
+		return settings, fmt.Errorf("%s: %w", SETTINGS_KEY, err)
+	}
+	return settings, nil
+}
+
+// Check reports whether an instrument is under maintenance, either on its own
+// or because everything is. An empty instrument name checks only the global
+// setting.
+func (maintenance *Maintenance) Check(instrumentName string) (bool, Window) {
+	maintenance.mutex.RLock()
+	defer maintenance.mutex.RUnlock()
+
+	if maintenance.settings.Global.Enabled {
+		return true, maintenance.settings.Global
+	}
+	if instrumentName == "" {
+		return false, Window{}
+	}
+	patterns := make([]string, 0, len(maintenance.settings.Instruments))
+	for pattern := range maintenance.settings.Instruments {
+		patterns = append(patterns, pattern)
+	}
+	pattern, found := utils.MatchInstrument(instrumentName, patterns)
+	if !found || !maintenance.settings.Instruments[pattern].Enabled {
+		return false, Window{}
+	}
+	return true, maintenance.settings.Instruments[pattern]
+}
+
+// Reload reads the settings again if they have changed since they were last
+// read. The settings in use are only replaced once the new ones have been
+// read successfully.
+func (maintenance *Maintenance) Reload() error {
+	settingsJSON, err := maintenance.Store.Get(SETTINGS_KEY)
+	if err != nil {
+		return err
+	}
+
+	maintenance.mutex.RLock()
+	unchanged := settingsJSON == maintenance.loaded
+	maintenance.mutex.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	settings, err := parseSettings(settingsJSON)
+	if err != nil {
+		return err
+	}
+
+	maintenance.mutex.Lock()
+	maintenance.settings = settings
+	maintenance.loaded = settingsJSON
+	maintenance.mutex.Unlock()
+
+	enabledInstruments := []string{}
+	for pattern, window := range settings.Instruments {
+		if window.Enabled {
+			enabledInstruments = append(enabledInstruments, pattern)
+		}
+	}
+	maintenance.Logger.Info("Loaded maintenance settings",
+		zap.Bool("GlobalMaintenance", settings.Global.Enabled),
+		zap.Strings("InstrumentsInMaintenance", enabledInstruments),
+	)
+	return nil
+}
+
+// Watch reloads the settings every interval, for as long as the server runs
+func (maintenance *Maintenance) Watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	for range time.Tick(interval) {
+		if err := maintenance.Reload(); err != nil {
+			maintenance.Logger.Error("Could not reload maintenance settings, keeping the previous settings",
+				zap.Error(err))
+		}
+	}
+}
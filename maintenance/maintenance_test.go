@@ -0,0 +1,116 @@
+package maintenance_test
+
+import (
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	"github.com/ONSdigital/blaise-cawi-portal/maintenance"
+	"go.uber.org/zap"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Maintenance", func() {
+	var (
+		store       *kvstore.MemoryStore
+		mode        *maintenance.Maintenance
+		writeConfig func(string)
+	)
+
+	underMaintenance := func(instrumentName string) bool {
+		enabled, _ := mode.Check(instrumentName)
+		return enabled
+	}
+
+	BeforeEach(func() {
+		store = kvstore.NewMemoryStore()
+		writeConfig = func(config string) {
+			Expect(store.Set(maintenance.SETTINGS_KEY, config, 0)).To(Succeed())
+		}
+	})
+
+	JustBeforeEach(func() {
+		mode = maintenance.NewMaintenance(store, zap.NewNop())
+	})
+
+	Context("with global maintenance on", func() {
+		BeforeEach(func() {
+			writeConfig(`{"global": {"enabled": true, "until": "2021-06-01T09:00:00Z"}}`)
+		})
+
+		It("puts everything under maintenance", func() {
+			underMaintenance, window := mode.Check("")
+			Expect(underMaintenance).To(BeTrue())
+			Expect(*window.Until).To(BeTemporally("==", time.Date(2021, 6, 1, 9, 0, 0, 0, time.UTC)))
+
+			underMaintenance, _ = mode.Check("dst2101a")
+			Expect(underMaintenance).To(BeTrue())
+		})
+	})
+
+	Context("with maintenance on for some instruments", func() {
+		BeforeEach(func() {
+			writeConfig(`{"instruments": {"dst21*": {"enabled": true}, "dst2101a": {"enabled": false}}}`)
+		})
+
+		It("puts only those instruments under maintenance", func() {
+			Expect(underMaintenance("dst2102a")).To(BeTrue())
+			Expect(underMaintenance("dst2101a")).To(BeFalse())
+			Expect(underMaintenance("opn2101a")).To(BeFalse())
+			Expect(underMaintenance("")).To(BeFalse())
+		})
+	})
+
+	Context("when the settings change", func() {
+		BeforeEach(func() {
+			writeConfig(`{"global": {"enabled": false}}`)
+		})
+
+		It("picks up the new settings on reload", func() {
+			Expect(underMaintenance("")).To(BeFalse())
+			writeConfig(`{"global": {"enabled": true}}`)
+			Expect(mode.Reload()).To(Succeed())
+			Expect(underMaintenance("")).To(BeTrue())
+		})
+
+		It("keeps the previous settings when the new ones are invalid", func() {
+			writeConfig(`{"global": {"enabled": true`)
+			Expect(mode.Reload()).ToNot(Succeed())
+			Expect(underMaintenance("")).To(BeFalse())
+		})
+
+		It("is seen by every instance sharing the store", func() {
+			otherInstance := maintenance.NewMaintenance(store, zap.NewNop())
+			Expect(maintenance.SaveSettings(store, maintenance.Settings{
+				Instruments: map[string]maintenance.Window{"dst21*": {Enabled: true}},
+			})).To(Succeed())
+
+			Expect(mode.Reload()).To(Succeed())
+			Expect(otherInstance.Reload()).To(Succeed())
+			Expect(underMaintenance("dst2101a")).To(BeTrue())
+			otherUnderMaintenance, _ := otherInstance.Check("dst2101a")
+			Expect(otherUnderMaintenance).To(BeTrue())
+		})
+	})
+
+	Context("without any settings", func() {
+		It("is never under maintenance", func() {
+			Expect(underMaintenance("dst2101a")).To(BeFalse())
+			Expect(mode.Reload()).To(Succeed())
+		})
+	})
+
+	Describe("SaveSettings", func() {
+		It("stores settings for LoadSettings to read back", func() {
+			until := time.Date(2021, 6, 1, 9, 0, 0, 0, time.UTC)
+			settings := maintenance.Settings{Global: maintenance.Window{Enabled: true, Until: &until}}
+			Expect(maintenance.SaveSettings(store, settings)).To(Succeed())
+
+			loaded, err := maintenance.LoadSettings(store)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded.Global.Enabled).To(BeTrue())
+			Expect(*loaded.Global.Until).To(BeTemporally("==", until))
+		})
+	})
+})
@@ -0,0 +1,95 @@
+package serverpark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+)
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name ResolverInterface
+type ResolverInterface interface {
+	Resolve(string) Route
+}
+
+// Route is the server park an instrument is installed on and the CATI
+// service that runs its interviews
+type Route struct {
+	Serverpark string `json:"serverpark"`
+	CatiUrl    string `json:"catiUrl"`
+}
+
+// Resolver routes each instrument pattern, an instrument name or a prefix
+// such as "dst21*", to a server park. Instruments without a route, and any
+// part of a route left empty, go to Default.
+type Resolver struct {
+	Default Route
+	Routes  map[string]Route
+}
+
+func (resolver *Resolver) Resolve(instrumentName string) Route {
+	patterns := make([]string, 0, len(resolver.Routes))
+	for pattern := range resolver.Routes {
+		patterns = append(patterns, pattern)
+	}
+	pattern, found := utils.MatchInstrument(instrumentName, patterns)
+	if !found {
+		return resolver.Default
+	}
+	route := resolver.Routes[pattern]
+	if route.Serverpark == "" {
+		route.Serverpark = resolver.Default.Serverpark
+	}
+	if route.CatiUrl == "" {
+		route.CatiUrl = resolver.Default.CatiUrl
+	}
+	return route
+}
+
+// ParseRoutes parses a JSON object of instrument patterns to their route,
+// for example:
+//
+//	{"lms*": {"serverpark": "lms", "catiUrl": "https://cati-lms.example.com"}}
+func ParseRoutes(data []byte) (map[string]Route, error) {
+	var routes map[string]Route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, err
+	}
+	for pattern, route := range routes {
+		if route.Serverpark == "" && route.CatiUrl == "" {
+			return nil, fmt.Errorf("route for %q has no serverpark or catiUrl", pattern)
+		}
+		if route.CatiUrl == "" {
+			continue
+		}
+		catiUrl, err := url.Parse(route.CatiUrl)
+		if err != nil || catiUrl.Scheme == "" || catiUrl.Host == "" {
+			return nil, fmt.Errorf("route for %q has an invalid catiUrl %q", pattern, route.CatiUrl)
+		}
+		route.CatiUrl = strings.TrimSuffix(route.CatiUrl, "/")
+		routes[pattern] = route
+	}
+	return routes, nil
+}
+
+// LoadResolver loads the routes in routesFile, if there is one, in front of
+// the default route
+func LoadResolver(routesFile string, defaultRoute Route) (*Resolver, error) {
+	resolver := &Resolver{Default: defaultRoute, Routes: map[string]Route{}}
+	if routesFile == "" {
+		return resolver, nil
+	}
+	fileContents, err := ioutil.ReadFile(routesFile)
+	if err != nil {
+		return nil, err
+	}
+	resolver.Routes, err = ParseRoutes(fileContents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", routesFile, err)
+	}
+	return resolver, nil
+}
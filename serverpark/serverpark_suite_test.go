@@ -0,0 +1,13 @@
+package serverpark_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerpark(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Serverpark Suite")
+}
@@ -0,0 +1,78 @@
+package serverpark_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ONSdigital/blaise-cawi-portal/serverpark"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resolver", func() {
+	var resolver *serverpark.Resolver
+
+	BeforeEach(func() {
+		routes, err := serverpark.ParseRoutes([]byte(`{
+			"lms*": {"serverpark": "lms", "catiUrl": "https://cati-lms.example.com/"},
+			"lms2101b": {"serverpark": "lms-pilot"},
+			"opn*": {"catiUrl": "https://cati-opn.example.com"}
+		}`))
+		Expect(err).ToNot(HaveOccurred())
+		resolver = &serverpark.Resolver{
+			Default: serverpark.Route{Serverpark: "gusty", CatiUrl: "https://cati.example.com"},
+			Routes:  routes,
+		}
+	})
+
+	DescribeTable("routes an instrument to its server park",
+		func(instrumentName string, expected serverpark.Route) {
+			Expect(resolver.Resolve(instrumentName)).To(Equal(expected))
+		},
+		Entry("by prefix", "LMS2101A",
+			serverpark.Route{Serverpark: "lms", CatiUrl: "https://cati-lms.example.com"}),
+		Entry("by exact name over a prefix, defaulting the CATI url", "lms2101b",
+			serverpark.Route{Serverpark: "lms-pilot", CatiUrl: "https://cati.example.com"}),
+		Entry("defaulting the server park", "opn2101a",
+			serverpark.Route{Serverpark: "gusty", CatiUrl: "https://cati-opn.example.com"}),
+		Entry("without a route", "dst2106a",
+			serverpark.Route{Serverpark: "gusty", CatiUrl: "https://cati.example.com"}),
+	)
+
+	Describe("ParseRoutes", func() {
+		It("rejects an empty route", func() {
+			_, err := serverpark.ParseRoutes([]byte(`{"lms*": {}}`))
+			Expect(err).To(MatchError(`route for "lms*" has no serverpark or catiUrl`))
+		})
+
+		It("rejects a CATI url that is not absolute", func() {
+			_, err := serverpark.ParseRoutes([]byte(`{"lms*": {"catiUrl": "cati-lms"}}`))
+			Expect(err).To(MatchError(`route for "lms*" has an invalid catiUrl "cati-lms"`))
+		})
+	})
+
+	Describe("LoadResolver", func() {
+		var defaultRoute = serverpark.Route{Serverpark: "gusty", CatiUrl: "https://cati.example.com"}
+
+		It("only has the default route without a file", func() {
+			resolver, err := serverpark.LoadResolver("", defaultRoute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resolver.Resolve("lms2101a")).To(Equal(defaultRoute))
+		})
+
+		It("loads routes from a file", func() {
+			dir, err := ioutil.TempDir("", "serverpark")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			routesFile := filepath.Join(dir, "serverparks.json")
+			Expect(ioutil.WriteFile(routesFile, []byte(`{"lms*": {"serverpark": "lms"}}`), 0644)).To(Succeed())
+
+			resolver, err := serverpark.LoadResolver(routesFile, defaultRoute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resolver.Resolve("lms2101a").Serverpark).To(Equal("lms"))
+		})
+	})
+})
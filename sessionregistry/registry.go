@@ -0,0 +1,82 @@
+package sessionregistry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+)
+
+const (
+	KEY_PREFIX = "session_registry"
+
+	// POLICY_EVICT lets a new login take over from a UAC's existing session
+	POLICY_EVICT = "evict"
+	// POLICY_REFUSE refuses a new login while a UAC's existing session is
+	// still active
+	POLICY_REFUSE = "refuse"
+)
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name RegistryInterface
+type RegistryInterface interface {
+	Register(string, string, time.Duration) (bool, error)
+	Active(string, string) (bool, error)
+	Refresh(string, string, time.Duration) error
+	Revoke(string) error
+}
+
+// Registry records the one active session for each UAC, by the session
+// token's ID, so that only that session is accepted. Entries expire with the
+// session they record.
+type Registry struct {
+	Store  kvstore.StoreInterface
+	Policy string
+}
+
+func NewRegistry(store kvstore.StoreInterface, policy string) (*Registry, error) {
+	switch policy {
+	case "":
+		policy = POLICY_EVICT
+	case POLICY_EVICT, POLICY_REFUSE:
+	default:
+		return nil, fmt.Errorf("unknown session policy %q, expected %q or %q", policy, POLICY_EVICT, POLICY_REFUSE)
+	}
+	return &Registry{Store: store, Policy: policy}, nil
+}
+
+// Register makes sessionID the active session for uacRef. It returns false
+// if the policy is to refuse and another session is still active.
+func (registry *Registry) Register(uacRef, sessionID string, ttl time.Duration) (bool, error) {
+	if registry.Policy == POLICY_REFUSE {
+		return registry.Store.SetNX(key(uacRef), sessionID, ttl)
+	}
+	if err := registry.Store.Set(key(uacRef), sessionID, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (registry *Registry) Active(uacRef, sessionID string) (bool, error) {
+	activeSessionID, err := registry.Store.Get(key(uacRef))
+	if err != nil {
+		return false, err
+	}
+	return activeSessionID != "" && activeSessionID == sessionID, nil
+}
+
+// Refresh extends the life of sessionID's entry, if it is still the active
+// session for uacRef. The check and the new expiry are one step in the
+// store, so a session registered in between is never overwritten.
+func (registry *Registry) Refresh(uacRef, sessionID string, ttl time.Duration) error {
+	_, err := registry.Store.ExpireIfEqual(key(uacRef), sessionID, ttl)
+	return err
+}
+
+func (registry *Registry) Revoke(uacRef string) error {
+	return registry.Store.Del(key(uacRef))
+}
+
+func key(uacRef string) string {
+	return fmt.Sprintf("%s:%s", KEY_PREFIX, uacRef)
+}
@@ -0,0 +1,108 @@
+package sessionregistry_test
+
+import (
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	"github.com/ONSdigital/blaise-cawi-portal/sessionregistry"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		store    *kvstore.MemoryStore
+		registry *sessionregistry.Registry
+		policy   string
+	)
+
+	JustBeforeEach(func() {
+		store = kvstore.NewMemoryStore()
+		var err error
+		registry, err = sessionregistry.NewRegistry(store, policy)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("with the evict policy", func() {
+		BeforeEach(func() {
+			policy = sessionregistry.POLICY_EVICT
+		})
+
+		It("makes the newest session the active one", func() {
+			Expect(registry.Register("uac", "first", time.Minute)).To(BeTrue())
+			Expect(registry.Register("uac", "second", time.Minute)).To(BeTrue())
+
+			Expect(registry.Active("uac", "first")).To(BeFalse())
+			Expect(registry.Active("uac", "second")).To(BeTrue())
+		})
+
+		It("keeps sessions for different UACs apart", func() {
+			registry.Register("uac", "first", time.Minute)
+			registry.Register("other", "second", time.Minute)
+
+			Expect(registry.Active("uac", "first")).To(BeTrue())
+			Expect(registry.Active("other", "second")).To(BeTrue())
+		})
+	})
+
+	Context("with the refuse policy", func() {
+		BeforeEach(func() {
+			policy = sessionregistry.POLICY_REFUSE
+		})
+
+		It("refuses a second session while the first is active", func() {
+			Expect(registry.Register("uac", "first", time.Minute)).To(BeTrue())
+			Expect(registry.Register("uac", "second", time.Minute)).To(BeFalse())
+
+			Expect(registry.Active("uac", "first")).To(BeTrue())
+			Expect(registry.Active("uac", "second")).To(BeFalse())
+		})
+
+		It("allows a new session once the first is revoked", func() {
+			registry.Register("uac", "first", time.Minute)
+			Expect(registry.Revoke("uac")).To(Succeed())
+
+			Expect(registry.Register("uac", "second", time.Minute)).To(BeTrue())
+		})
+	})
+
+	Describe("Refresh", func() {
+		BeforeEach(func() {
+			policy = ""
+		})
+
+		It("extends the active session", func() {
+			registry.Register("uac", "first", time.Minute)
+			Expect(registry.Refresh("uac", "first", time.Hour)).To(Succeed())
+			Expect(store.TTL("session_registry:uac")).To(BeNumerically(">", time.Minute))
+		})
+
+		It("does not bring back an evicted session", func() {
+			registry.Register("uac", "first", time.Minute)
+			registry.Register("uac", "second", time.Minute)
+			Expect(registry.Refresh("uac", "first", time.Hour)).To(Succeed())
+			Expect(registry.Active("uac", "second")).To(BeTrue())
+		})
+
+		It("does not register a session that was never registered", func() {
+			Expect(registry.Refresh("uac", "first", time.Hour)).To(Succeed())
+			Expect(registry.Active("uac", "first")).To(BeFalse())
+		})
+	})
+
+	Describe("Revoke", func() {
+		It("ends the active session", func() {
+			registry.Register("uac", "first", time.Minute)
+			Expect(registry.Revoke("uac")).To(Succeed())
+			Expect(registry.Active("uac", "first")).To(BeFalse())
+		})
+	})
+
+	Describe("NewRegistry", func() {
+		It("rejects an unknown policy", func() {
+			_, err := sessionregistry.NewRegistry(store, "share")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
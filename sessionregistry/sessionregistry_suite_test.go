@@ -0,0 +1,13 @@
+package sessionregistry_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSessionregistry(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sessionregistry Suite")
+}
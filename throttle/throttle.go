@@ -0,0 +1,165 @@
+package throttle
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	THROTTLE_ID_KEY = "throttle_id"
+	KEY_PREFIX      = "throttle"
+	STRIKE_LIFETIME = 24 * time.Hour
+)
+
+var DefaultBackoff = []time.Duration{
+	1 * time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	60 * time.Minute,
+}
+
+//Generate mocks by running "go generate ./..."
+//go:generate mockery --name ThrottleInterface
+type ThrottleInterface interface {
+	Throttled(*gin.Context) time.Duration
+	RecordFailure(*gin.Context)
+}
+
+// Throttle counts failed login attempts per client IP and per browser
+// session. Once either passes its limit within the attempt window, further
+// attempts are refused for the next backoff window, with each repeat offence
+// moving on to a longer window.
+type Throttle struct {
+	Store              kvstore.StoreInterface
+	Logger             *zap.Logger
+	SessionName        string
+	MaxIPAttempts      int64
+	MaxSessionAttempts int64
+	AttemptWindow      time.Duration
+	Backoff            []time.Duration
+}
+
+type subject struct {
+	kind  string
+	id    string
+	limit int64
+}
+
+func (subject subject) key(suffix string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", KEY_PREFIX, subject.kind, subject.id, suffix)
+}
+
+// Throttled returns how long the client must wait before trying again, or
+// zero if they are free to make an attempt
+func (throttle *Throttle) Throttled(context *gin.Context) time.Duration {
+	var retryAfter time.Duration
+	for _, subject := range throttle.subjects(context) {
+		remaining, err := throttle.Store.TTL(subject.key("lockout"))
+		if err != nil {
+			throttle.Logger.Error("Could not check login throttle", append(utils.GetRequestSource(context),
+				zap.String("ThrottleKind", subject.kind), zap.Error(err))...)
+			continue
+		}
+		if remaining > retryAfter {
+			retryAfter = remaining
+		}
+	}
+	return retryAfter
+}
+
+func (throttle *Throttle) RecordFailure(context *gin.Context) {
+	for _, subject := range throttle.subjects(context) {
+		if err := throttle.recordFailure(subject); err != nil {
+			throttle.Logger.Error("Could not record failed login attempt", append(utils.GetRequestSource(context),
+				zap.String("ThrottleKind", subject.kind), zap.Error(err))...)
+		}
+	}
+}
+
+func (throttle *Throttle) recordFailure(subject subject) error {
+	failures, err := throttle.Store.Incr(subject.key("failures"), throttle.AttemptWindow)
+	if err != nil {
+		return err
+	}
+	if subject.limit <= 0 || failures < subject.limit {
+		return nil
+	}
+
+	strikes, err := throttle.Store.Incr(subject.key("strikes"), STRIKE_LIFETIME)
+	if err != nil {
+		return err
+	}
+	if err := throttle.Store.Set(subject.key("lockout"), "1", throttle.backoff(strikes)); err != nil {
+		return err
+	}
+	return throttle.Store.Del(subject.key("failures"))
+}
+
+func (throttle *Throttle) backoff(strikes int64) time.Duration {
+	backoff := throttle.Backoff
+	if len(backoff) == 0 {
+		backoff = DefaultBackoff
+	}
+	index := int(strikes) - 1
+	if index >= len(backoff) {
+		index = len(backoff) - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	return backoff[index]
+}
+
+func (throttle *Throttle) subjects(context *gin.Context) []subject {
+	var subjects []subject
+	if clientIP := context.ClientIP(); clientIP != "" {
+		subjects = append(subjects, subject{kind: "ip", id: clientIP, limit: throttle.MaxIPAttempts})
+	}
+	if sessionID := throttle.sessionID(context); sessionID != "" {
+		subjects = append(subjects, subject{kind: "session", id: sessionID, limit: throttle.MaxSessionAttempts})
+	}
+	return subjects
+}
+
+// sessionID returns a random identifier for the browser session, creating
+// one the first time the session is seen
+func (throttle *Throttle) sessionID(context *gin.Context) string {
+	session := sessions.DefaultMany(context, throttle.SessionName)
+	if sessionID, ok := session.Get(THROTTLE_ID_KEY).(string); ok && sessionID != "" {
+		return sessionID
+	}
+
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		throttle.Logger.Error("Could not generate throttle session ID", zap.Error(err))
+		return ""
+	}
+	sessionID := hex.EncodeToString(randomBytes)
+	session.Set(THROTTLE_ID_KEY, sessionID)
+	if err := session.Save(); err != nil {
+		throttle.Logger.Error("Could not save throttle session ID", zap.Error(err))
+		return ""
+	}
+	return sessionID
+}
+
+// RetryAfterMinutes rounds a throttle duration up to whole minutes for
+// displaying to respondents
+func RetryAfterMinutes(retryAfter time.Duration) int {
+	minutes := int(retryAfter / time.Minute)
+	if retryAfter%time.Minute > 0 {
+		minutes++
+	}
+	if minutes < 1 {
+		minutes = 1
+	}
+	return minutes
+}
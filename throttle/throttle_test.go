@@ -0,0 +1,156 @@
+package throttle_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
+	"github.com/ONSdigital/blaise-cawi-portal/throttle"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Throttle", func() {
+	var (
+		httpRouter    *gin.Engine
+		memoryStore   *kvstore.MemoryStore
+		loginThrottle *throttle.Throttle
+		now           time.Time
+		retryAfter    time.Duration
+		cookies       []*http.Cookie
+	)
+
+	attempt := func(remoteAddr string, failed bool) {
+		httpRecorder := httptest.NewRecorder()
+		path := "/check"
+		if failed {
+			path = "/fail"
+		}
+		req, _ := http.NewRequest("POST", path, nil)
+		req.RemoteAddr = remoteAddr + ":1234"
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		httpRouter.ServeHTTP(httpRecorder, req)
+		if len(httpRecorder.Result().Cookies()) > 0 {
+			cookies = httpRecorder.Result().Cookies()
+		}
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2022, 1, 1, 9, 0, 0, 0, time.UTC)
+		memoryStore = kvstore.NewMemoryStore()
+		memoryStore.Now = func() time.Time { return now }
+		cookies = nil
+		loginThrottle = &throttle.Throttle{
+			Store:              memoryStore,
+			Logger:             zap.NewNop(),
+			SessionName:        "session",
+			MaxIPAttempts:      4,
+			MaxSessionAttempts: 2,
+			AttemptWindow:      15 * time.Minute,
+			Backoff:            []time.Duration{time.Minute, 5 * time.Minute},
+		}
+
+		httpRouter = gin.Default()
+		store := cookie.NewStore([]byte("secret"))
+		httpRouter.Use(sessions.SessionsMany([]string{"session"}, store))
+		httpRouter.POST("/fail", func(context *gin.Context) {
+			loginThrottle.RecordFailure(context)
+		})
+		httpRouter.POST("/check", func(context *gin.Context) {
+			retryAfter = loginThrottle.Throttled(context)
+		})
+	})
+
+	Context("with no failed attempts", func() {
+		It("is not throttled", func() {
+			attempt("1.1.1.1", false)
+			Expect(retryAfter).To(Equal(time.Duration(0)))
+		})
+	})
+
+	Context("when a browser session reaches its limit", func() {
+		BeforeEach(func() {
+			attempt("1.1.1.1", true)
+			attempt("1.1.1.1", true)
+		})
+
+		It("throttles for the first backoff window", func() {
+			attempt("1.1.1.1", false)
+			Expect(retryAfter).To(Equal(time.Minute))
+		})
+
+		It("throttles the session from a different IP", func() {
+			attempt("2.2.2.2", false)
+			Expect(retryAfter).To(Equal(time.Minute))
+		})
+
+		It("does not throttle a different session", func() {
+			cookies = nil
+			attempt("2.2.2.2", false)
+			Expect(retryAfter).To(Equal(time.Duration(0)))
+		})
+
+		It("stops throttling once the backoff window has passed", func() {
+			now = now.Add(time.Minute)
+			attempt("1.1.1.1", false)
+			Expect(retryAfter).To(Equal(time.Duration(0)))
+		})
+
+		It("backs off for longer when the limit is reached again", func() {
+			now = now.Add(time.Minute)
+			attempt("1.1.1.1", true)
+			attempt("1.1.1.1", true)
+			attempt("1.1.1.1", false)
+			Expect(retryAfter).To(Equal(5 * time.Minute))
+		})
+	})
+
+	Context("when an IP reaches its limit across sessions", func() {
+		BeforeEach(func() {
+			for i := 0; i < 4; i++ {
+				cookies = nil
+				attempt("1.1.1.1", true)
+			}
+			cookies = nil
+		})
+
+		It("throttles a new session from the same IP", func() {
+			attempt("1.1.1.1", false)
+			Expect(retryAfter).To(Equal(time.Minute))
+		})
+
+		It("does not throttle a different IP", func() {
+			attempt("2.2.2.2", false)
+			Expect(retryAfter).To(Equal(time.Duration(0)))
+		})
+	})
+
+	Context("when failures are spread beyond the attempt window", func() {
+		It("is not throttled", func() {
+			attempt("1.1.1.1", true)
+			now = now.Add(15 * time.Minute)
+			attempt("1.1.1.1", true)
+			attempt("1.1.1.1", false)
+			Expect(retryAfter).To(Equal(time.Duration(0)))
+		})
+	})
+})
+
+var _ = DescribeTable("RetryAfterMinutes",
+	func(retryAfter time.Duration, expected int) {
+		Expect(throttle.RetryAfterMinutes(retryAfter)).To(Equal(expected))
+	},
+	Entry("under a minute", 20*time.Second, 1),
+	Entry("exactly a minute", time.Minute, 1),
+	Entry("part way through a minute", 4*time.Minute+time.Second, 5),
+	Entry("whole minutes", 15*time.Minute, 15),
+)
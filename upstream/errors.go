@@ -0,0 +1,122 @@
+// Package upstream holds the errors returned when a service the portal calls,
+// BUS or CATI, fails. Each names the service in Upstream so that the messages
+// say which one went wrong.
+package upstream
+
+import (
+	"fmt"
+)
+
+// MAX_ERROR_BODY_LENGTH is how much of a response body is kept on an error,
+// enough to see what went wrong without logging whole pages
+const MAX_ERROR_BODY_LENGTH = 512
+
+// Response is what the service sent back when a call failed. StatusCode is
+// zero when the service could not be reached at all.
+type Response struct {
+	StatusCode int
+	Body       string
+}
+
+// NewResponse keeps the status code and the start of the body
+func NewResponse(statusCode int, body []byte) Response {
+	if len(body) > MAX_ERROR_BODY_LENGTH {
+		body = append(body[:MAX_ERROR_BODY_LENGTH:MAX_ERROR_BODY_LENGTH], "..."...)
+	}
+	return Response{StatusCode: statusCode, Body: string(body)}
+}
+
+func (response Response) String() string {
+	if response.StatusCode == 0 {
+		return "no response"
+	}
+	return fmt.Sprintf("status %d", response.StatusCode)
+}
+
+// TimeoutError is returned when the service does not respond before the
+// caller's timeout, or the transport's own
+type TimeoutError struct {
+	Upstream string
+	Err      error
+}
+
+func (err *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out: %s", err.Upstream, err.Err)
+}
+
+func (err *TimeoutError) Unwrap() error {
+	return err.Err
+}
+
+// UnavailableError is returned when the service cannot be reached or
+// responds with a server error. Err is the transport error, if there was one.
+type UnavailableError struct {
+	Upstream string
+	Response
+	Err error
+}
+
+func (err *UnavailableError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("%s unavailable: %s", err.Upstream, err.Err)
+	}
+	return fmt.Sprintf("%s unavailable (%s)", err.Upstream, err.Response)
+}
+
+func (err *UnavailableError) Unwrap() error {
+	return err.Err
+}
+
+// UnauthorizedError is returned when the service refuses the portal's
+// credentials
+type UnauthorizedError struct {
+	Upstream string
+	Response
+}
+
+func (err *UnauthorizedError) Error() string {
+	return fmt.Sprintf("not authorised to call %s (%s)", err.Upstream, err.Response)
+}
+
+// NotFoundError is returned when the service does not know what was asked
+// for, a UAC in BUS or an instrument in CATI
+type NotFoundError struct {
+	Upstream string
+	Response
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("not found in %s (%s)", err.Upstream, err.Response)
+}
+
+// RedirectError is returned when the service redirects rather than
+// answering. Redirects are not followed, so the caller decides where the
+// respondent goes.
+type RedirectError struct {
+	Upstream string
+	Response
+	Location string
+}
+
+func (err *RedirectError) Error() string {
+	return fmt.Sprintf("%s redirected to %q (%s)", err.Upstream, err.Location, err.Response)
+}
+
+// MalformedResponseError is returned when the service responds with a status
+// the portal does not expect, or a body that cannot be read
+type MalformedResponseError struct {
+	Upstream string
+	Response
+	Err error
+}
+
+func (err *MalformedResponseError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("malformed response from %s (%s): %s", err.Upstream, err.Response, err.Err)
+	}
+	return fmt.Sprintf("unexpected response from %s (%s)", err.Upstream, err.Response)
+}
+
+func (err *MalformedResponseError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,58 @@
+package upstream_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewResponse", func() {
+	It("keeps a short body whole", func() {
+		Expect(upstream.NewResponse(500, []byte("down"))).To(Equal(upstream.Response{StatusCode: 500, Body: "down"}))
+	})
+
+	It("cuts a long body short", func() {
+		body := []byte(strings.Repeat("a", upstream.MAX_ERROR_BODY_LENGTH+1))
+
+		response := upstream.NewResponse(500, body)
+
+		Expect(response.Body).To(Equal(strings.Repeat("a", upstream.MAX_ERROR_BODY_LENGTH) + "..."))
+		Expect(body[upstream.MAX_ERROR_BODY_LENGTH]).To(Equal(byte('a')))
+	})
+})
+
+var _ = Describe("errors", func() {
+	var transportError = errors.New("connection refused")
+
+	DescribeTable("name the upstream",
+		func(err error, expectedMessage string) {
+			Expect(err).To(MatchError(expectedMessage))
+		},
+		Entry("timeout", &upstream.TimeoutError{Upstream: "CATI", Err: transportError},
+			"CATI timed out: connection refused"),
+		Entry("unavailable, without a response", &upstream.UnavailableError{Upstream: "BUS", Err: transportError},
+			"BUS unavailable: connection refused"),
+		Entry("unavailable, with a response", &upstream.UnavailableError{Upstream: "BUS", Response: upstream.Response{StatusCode: 503}},
+			"BUS unavailable (status 503)"),
+		Entry("unauthorised", &upstream.UnauthorizedError{Upstream: "BUS", Response: upstream.Response{StatusCode: 401}},
+			"not authorised to call BUS (status 401)"),
+		Entry("not found", &upstream.NotFoundError{Upstream: "CATI", Response: upstream.Response{StatusCode: 404}},
+			"not found in CATI (status 404)"),
+		Entry("redirect", &upstream.RedirectError{Upstream: "CATI", Response: upstream.Response{StatusCode: 302}, Location: "/login"},
+			`CATI redirected to "/login" (status 302)`),
+		Entry("malformed", &upstream.MalformedResponseError{Upstream: "BUS", Response: upstream.Response{StatusCode: 200}, Err: transportError},
+			"malformed response from BUS (status 200): connection refused"),
+		Entry("unexpected", &upstream.MalformedResponseError{Upstream: "BUS", Response: upstream.Response{StatusCode: 400}},
+			"unexpected response from BUS (status 400)"),
+	)
+
+	It("unwraps to the transport error", func() {
+		var err error = &upstream.UnavailableError{Upstream: "BUS", Err: transportError}
+		Expect(errors.Is(err, transportError)).To(BeTrue())
+	})
+})
@@ -0,0 +1,28 @@
+package utils
+
+import "strings"
+
+// MatchInstrument finds the pattern that applies to an instrument. Patterns
+// are either an instrument name, or a prefix ending in * such as "dst21*".
+// An exact name wins over a prefix, and a longer prefix over a shorter one.
+// Matching ignores case, as instrument names do elsewhere.
+func MatchInstrument(instrumentName string, patterns []string) (string, bool) {
+	var (
+		bestMatch  string
+		bestLength = -1
+	)
+	lowerInstrumentName := strings.ToLower(instrumentName)
+	for _, pattern := range patterns {
+		if strings.EqualFold(pattern, instrumentName) {
+			return pattern, true
+		}
+		if !strings.HasSuffix(pattern, "*") {
+			continue
+		}
+		prefix := strings.ToLower(strings.TrimSuffix(pattern, "*"))
+		if strings.HasPrefix(lowerInstrumentName, prefix) && len(prefix) > bestLength {
+			bestMatch, bestLength = pattern, len(prefix)
+		}
+	}
+	return bestMatch, bestLength >= 0
+}
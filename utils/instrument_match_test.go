@@ -0,0 +1,33 @@
+package utils_test
+
+import (
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MatchInstrument", func() {
+	var patterns = []string{"dst*", "dst21*", "DST2101A", "lms2102_bk1"}
+
+	DescribeTable("finds the pattern for an instrument",
+		func(instrumentName, expectedPattern string, expectedFound bool) {
+			pattern, found := utils.MatchInstrument(instrumentName, patterns)
+			Expect(found).To(Equal(expectedFound))
+			Expect(pattern).To(Equal(expectedPattern))
+		},
+		Entry("exact name, ignoring case", "dst2101a", "DST2101A", true),
+		Entry("longest prefix", "dst2102a", "dst21*", true),
+		Entry("shorter prefix", "dst2201a", "dst*", true),
+		Entry("exact name only", "lms2102_bk1", "lms2102_bk1", true),
+		Entry("no prefix match on an exact name", "lms2102_bk2", "", false),
+		Entry("no match", "opn2101a", "", false),
+	)
+
+	It("matches everything with a bare *", func() {
+		pattern, found := utils.MatchInstrument("opn2101a", []string{"*"})
+		Expect(found).To(BeTrue())
+		Expect(pattern).To(Equal("*"))
+	})
+})
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout bounds a call to another service by the caller's context and,
+// when timeout is set, a deadline of its own, so that a slow service cannot
+// hold up a respondent's request for longer than the timeout allows
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,33 @@
+package utils_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithTimeout", func() {
+	It("sets a deadline when there is a timeout", func() {
+		ctx, cancel := utils.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		Expect(ok).To(BeTrue())
+		Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+	})
+
+	It("only follows the caller's context without a timeout", func() {
+		parent, cancelParent := context.WithCancel(context.Background())
+		ctx, cancel := utils.WithTimeout(parent, 0)
+		defer cancel()
+
+		_, ok := ctx.Deadline()
+		Expect(ok).To(BeFalse())
+		cancelParent()
+		Expect(ctx.Err()).To(Equal(context.Canceled))
+	})
+})
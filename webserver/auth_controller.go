@@ -6,6 +6,7 @@ import (
 
 	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
 	"github.com/ONSdigital/blaise-cawi-portal/languagemanager"
+	"github.com/ONSdigital/blaise-cawi-portal/maintenance"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	csrf "github.com/srbry/gin-csrf"
@@ -18,20 +19,27 @@ type AuthController struct {
 	UacKind         string
 	CSRFManager     csrf.CSRFManager
 	LanguageManager languagemanager.LanguageManagerInterface
+	Maintenance     maintenance.MaintenanceInterface
 }
 
 func (authController *AuthController) AddRoutes(httpRouter *gin.Engine) {
 	authGroup := httpRouter.Group("/auth")
 	authGroup.Use(authController.CSRFManager.Middleware())
 	{
-		authGroup.GET("/login", authController.LoginEndpoint)
-		authGroup.POST("/login", authController.PostLoginEndpoint)
+		authGroup.GET("/login", authController.CheckMaintenance, authController.LoginEndpoint)
+		authGroup.POST("/login", authController.CheckMaintenance, authController.PostLoginEndpoint)
+		authGroup.GET("/link/:token", authController.CheckMaintenance, authController.LinkEndpoint)
+		authGroup.POST("/link/:token", authController.CheckMaintenance, authController.LinkLoginEndpoint)
 		authGroup.GET("/logout", authController.LogoutEndpoint)
 		authGroup.GET("/logged-in", authController.LoggedInEndpoint)
 		authGroup.GET("/timed-out", authController.TimedOutEndpoint)
 	}
 }
 
+func (authController *AuthController) CheckMaintenance(context *gin.Context) {
+	checkMaintenance(context, authController.Maintenance, authController.Logger, authController.LanguageManager)
+}
+
 func (authController *AuthController) LoginEndpoint(context *gin.Context) {
 	hasSession, claim := authController.Auth.HasSession(context)
 	if hasSession {
@@ -39,17 +47,11 @@ func (authController *AuthController) LoginEndpoint(context *gin.Context) {
 		return
 	}
 
-	requestedLang := languagemanager.GetLangFromQuery(context)
-	currentlyWelsh := authController.LanguageManager.IsWelsh(context)
-	if requestedLang == "en" && currentlyWelsh {
-		authController.LanguageManager.SetWelsh(context, false)
-	}
-	if requestedLang == "cy" && !currentlyWelsh {
-		authController.LanguageManager.SetWelsh(context, true)
-	}
+	authController.setLanguageFromQuery(context)
 
 	context.HTML(http.StatusOK, "login.tmpl", gin.H{
-		"uac16":      authController.isUac16(),
+		"uac12":      authController.UacKind == authenticate.UAC12,
+		"uac16":      authController.UacKind == authenticate.UAC16,
 		"csrf_token": authController.CSRFManager.GetToken(context),
 		"welsh":      authController.LanguageManager.IsWelsh(context),
 	})
@@ -61,6 +63,25 @@ func (authController *AuthController) PostLoginEndpoint(context *gin.Context) {
 	authController.Auth.Login(context, session)
 }
 
+// LinkEndpoint is where a one-time link lands. It only shows a button to log
+// in with the link, so that mail scanners and link previews opening it do not
+// use it up.
+func (authController *AuthController) LinkEndpoint(context *gin.Context) {
+	authController.setLanguageFromQuery(context)
+
+	context.HTML(http.StatusOK, "link_login.tmpl", gin.H{
+		"token":      context.Param("token"),
+		"csrf_token": authController.CSRFManager.GetToken(context),
+		"welsh":      authController.LanguageManager.IsWelsh(context),
+	})
+}
+
+func (authController *AuthController) LinkLoginEndpoint(context *gin.Context) {
+	session := sessions.DefaultMany(context, "user_session")
+
+	authController.Auth.LinkLogin(context, session, context.Param("token"))
+}
+
 func (authController *AuthController) LogoutEndpoint(context *gin.Context) {
 	session := sessions.DefaultMany(context, "user_session")
 
@@ -79,6 +100,11 @@ func (authController *AuthController) LoggedInEndpoint(context *gin.Context) {
 func (authController *AuthController) TimedOutEndpoint(context *gin.Context) {
 	session := sessions.DefaultMany(context, "user_session")
 
+	if ended, claim := authController.Auth.LifetimeEnded(context); ended {
+		authController.Auth.SessionEnded(context, session, claim)
+		return
+	}
+
 	timeout := session.Get(authenticate.SESSION_TIMEOUT_KEY)
 	if timeout != nil {
 		timeout = timeout.(int)
@@ -87,12 +113,22 @@ func (authController *AuthController) TimedOutEndpoint(context *gin.Context) {
 		timeout = authenticate.DefaultAuthTimeout
 	}
 
+	saved, lost := authenticate.TimeoutAnswers(session)
 	context.HTML(http.StatusOK, "timeout.tmpl", gin.H{
 		"timeout": timeout,
+		"saved":   saved,
+		"lost":    lost,
 		"welsh":   authController.LanguageManager.IsWelsh(context),
 	})
 }
 
-func (authController *AuthController) isUac16() bool {
-	return authController.UacKind == "uac16"
+func (authController *AuthController) setLanguageFromQuery(context *gin.Context) {
+	requestedLang := languagemanager.GetLangFromQuery(context)
+	currentlyWelsh := authController.LanguageManager.IsWelsh(context)
+	if requestedLang == "en" && currentlyWelsh {
+		authController.LanguageManager.SetWelsh(context, false)
+	}
+	if requestedLang == "cy" && !currentlyWelsh {
+		authController.LanguageManager.SetWelsh(context, true)
+	}
 }
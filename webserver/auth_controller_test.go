@@ -23,6 +23,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -278,6 +279,87 @@ var _ = Describe("Auth Controller", func() {
 		})
 	})
 
+	Describe("GET /auth/link/:token", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		BeforeEach(func() {
+			languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+			languageManagerMock.On("SetWelsh", mock.Anything, mock.Anything).Return()
+		})
+
+		JustBeforeEach(func() {
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/auth/link/some-link-token?lang=cy", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		It("shows a button to log in with the link", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(`<form method="post" action="/auth/link/some-link-token">`))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(`name="_csrf"`))
+		})
+
+		It("does not use the link", func() {
+			mockAuth.AssertNotCalled(GinkgoT(), "LinkLogin", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("switches to the language requested in the link", func() {
+			languageManagerMock.AssertCalled(GinkgoT(), "SetWelsh", mock.Anything, true)
+		})
+	})
+
+	Describe("POST /auth/link/:token", func() {
+		var (
+			httpRecorder *httptest.ResponseRecorder
+		)
+
+		BeforeEach(func() {
+			languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+			mockAuth.On("LinkLogin", mock.Anything, mock.Anything, "some-link-token").Return()
+		})
+
+		Context("without a CSRF", func() {
+			JustBeforeEach(func() {
+				httpRecorder = httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/auth/link/some-link-token", nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("does not use the link", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+				mockAuth.AssertNotCalled(GinkgoT(), "LinkLogin", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("with a valid CSRF", func() {
+			var csrfToken string
+
+			JustBeforeEach(func() {
+				httpRouter.GET("/token", func(context *gin.Context) {
+					csrfToken = csrfManager.GetToken(context)
+				})
+
+				req1, _ := http.NewRequest("GET", "/token", nil)
+
+				httpRecorder = httptest.NewRecorder()
+				httpRouter.ServeHTTP(httpRecorder, req1)
+
+				req2, _ := http.NewRequest("POST", fmt.Sprintf("/auth/link/some-link-token?_csrf=%s", csrfToken), nil)
+				req2.Header.Set("Cookie", httpRecorder.Header().Get("Set-Cookie"))
+				req2.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+				httpRecorder = httptest.NewRecorder()
+				httpRouter.ServeHTTP(httpRecorder, req2)
+			})
+
+			It("calls auth.LinkLogin with the token", func() {
+				mockAuth.AssertNumberOfCalls(GinkgoT(), "LinkLogin", 1)
+			})
+		})
+	})
+
 	Describe("GET /auth/logout", func() {
 		var (
 			httpRecorder *httptest.ResponseRecorder
@@ -343,8 +425,22 @@ var _ = Describe("Auth Controller", func() {
 			httpRouter.ServeHTTP(httpRecorder, req)
 		})
 
+		Context("when the session reached its maximum lifetime", func() {
+			var claim = &authenticate.UACClaims{MaxLifetime: 3600}
+
+			BeforeEach(func() {
+				mockAuth.On("LifetimeEnded", mock.Anything).Return(true, claim)
+				mockAuth.On("SessionEnded", mock.Anything, mock.Anything, claim).Return()
+			})
+
+			It("shows the session ended page instead", func() {
+				mockAuth.AssertCalled(GinkgoT(), "SessionEnded", mock.Anything, mock.Anything, claim)
+			})
+		})
+
 		Context("in english", func() {
 			BeforeEach(func() {
+				mockAuth.On("LifetimeEnded", mock.Anything).Return(false, nil)
 				languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
 			})
 
@@ -359,6 +455,7 @@ var _ = Describe("Auth Controller", func() {
 
 		Context("in welsh", func() {
 			BeforeEach(func() {
+				mockAuth.On("LifetimeEnded", mock.Anything).Return(false, nil)
 				languageManagerMock.On("IsWelsh", mock.Anything).Return(true)
 			})
 
@@ -370,5 +467,60 @@ var _ = Describe("Auth Controller", func() {
 				Expect(body).To(ContainSubstring(`Bydd angen i chi <a href="/">fewngofnodi eto</a> i barhau â'ch astudiaeth.`))
 			})
 		})
+
+		Describe("with the instrument's StrictInterviewing settings in the session", func() {
+			var (
+				saveSessionOnTimeout   bool
+				deleteSessionOnTimeout bool
+				welsh                  bool
+			)
+
+			BeforeEach(func() {
+				httpRouter = gin.Default()
+				store := cookie.NewStore([]byte("secret"))
+				httpRouter.Use(sessions.SessionsMany([]string{"session", "user_session", "session_validation", "language_session"}, store))
+				httpRouter.Use(func(context *gin.Context) {
+					session := sessions.DefaultMany(context, "user_session")
+					session.Set(authenticate.SESSION_TIMEOUT_KEY, 30)
+					session.Set(authenticate.SAVE_SESSION_ON_TIMEOUT_KEY, saveSessionOnTimeout)
+					session.Set(authenticate.DELETE_SESSION_ON_TIMEOUT_KEY, deleteSessionOnTimeout)
+				})
+				httpRouter.SetFuncMap(template.FuncMap{
+					"WrapWelsh": webserver.WrapWelsh,
+				})
+				httpRouter.LoadHTMLGlob("../templates/*")
+				authController.AddRoutes(httpRouter)
+				mockAuth.On("LifetimeEnded", mock.Anything).Return(false, nil)
+				languageManagerMock.On("IsWelsh", mock.Anything).Return(func(*gin.Context) bool { return welsh })
+			})
+
+			DescribeTable("tells the respondent what happened to their answers",
+				func(save, delete, inWelsh bool, expected, unexpected string) {
+					saveSessionOnTimeout, deleteSessionOnTimeout, welsh = save, delete, inWelsh
+					httpRecorder = httptest.NewRecorder()
+					req, _ := http.NewRequest("GET", "/auth/timed-out", nil)
+					httpRouter.ServeHTTP(httpRecorder, req)
+
+					Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+					body := httpRecorder.Body.String()
+					Expect(body).To(ContainSubstring(expected))
+					if unexpected != "" {
+						Expect(body).ToNot(ContainSubstring(unexpected))
+					}
+				},
+				Entry("saved on timeout", true, false, false,
+					"<p>Your answers have been saved.</p>", "start your study again"),
+				Entry("saved then deleted on timeout", true, true, false,
+					"<p>Your answers have been saved.</p>", "start your study again"),
+				Entry("deleted without saving on timeout", false, true, false,
+					"<p>Your answers have not been saved, so you will need to start your study again.</p>", "Your answers have been saved"),
+				Entry("neither saved nor deleted on timeout", false, false, false,
+					"inactive for 30 minutes", "Your answers have"),
+				Entry("saved on timeout in welsh", true, false, true,
+					"<p>Mae eich atebion wedi cael eu cadw.</p>", "ddechrau eich astudiaeth eto"),
+				Entry("deleted without saving on timeout in welsh", false, true, true,
+					"<p>Nid yw eich atebion wedi cael eu cadw, felly bydd angen i chi ddechrau eich astudiaeth eto.</p>", "Mae eich atebion"),
+			)
+		})
 	})
 })
@@ -7,6 +7,12 @@ import (
 )
 
 func InternalServerError(context *gin.Context, welsh bool) {
-	context.HTML(http.StatusInternalServerError, "server_error.tmpl", gin.H{"welsh": welsh})
+	ServerError(context, http.StatusInternalServerError, welsh)
+}
+
+// ServerError renders the server error page with a status other than 500,
+// such as when CATI cannot be reached
+func ServerError(context *gin.Context, status int, welsh bool) {
+	context.HTML(status, "server_error.tmpl", gin.H{"welsh": welsh})
 	context.Abort()
 }
@@ -2,7 +2,9 @@ package webserver
 
 import (
 	"bytes"
+	stdcontext "context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,29 +13,62 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
 	"github.com/ONSdigital/blaise-cawi-portal/blaise"
+	"github.com/ONSdigital/blaise-cawi-portal/fieldperiod"
 	"github.com/ONSdigital/blaise-cawi-portal/languagemanager"
+	"github.com/ONSdigital/blaise-cawi-portal/maintenance"
+	"github.com/ONSdigital/blaise-cawi-portal/serverpark"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"golang.org/x/net/html"
 )
 
 type InstrumentController struct {
-	Auth            authenticate.AuthInterface
-	JWTCrypto       authenticate.JWTCryptoInterface
-	Logger          *zap.Logger
-	CatiUrl         string
-	HttpClient      *http.Client
+	Auth      authenticate.AuthInterface
+	JWTCrypto authenticate.JWTCryptoInterface
+	Logger    *zap.Logger
+	CatiUrl   string
+	// Serverparks, when set, picks the CATI service for each instrument in
+	// place of CatiUrl
+	Serverparks serverpark.ResolverInterface
+	Launcher    blaise.LauncherInterface
+	// Transport is shared by the proxies to each CATI service, or is
+	// http.DefaultTransport when nil
+	Transport http.RoundTripper
+	// CatiTimeout, when set, bounds each proxied call to CATI, along with the
+	// respondent's own request
+	CatiTimeout     time.Duration
 	Debug           bool
 	LanguageManager languagemanager.LanguageManagerInterface
+	FieldPeriods    fieldperiod.FieldPeriodsInterface
+	Maintenance     maintenance.MaintenanceInterface
+
+	proxiesMutex sync.Mutex
+	proxies      map[string]*httputil.ReverseProxy
+}
+
+// proxyRequestKey carries what the proxy's ErrorHandler needs to know about
+// the respondent's request through the proxied request's context
+type proxyRequestKey struct{}
+
+type proxyRequest struct {
+	context  *gin.Context
+	uacClaim *authenticate.UACClaims
 }
 
 func (instrumentController *InstrumentController) AddRoutes(httpRouter *gin.Engine) {
 	instrumentRouter := httpRouter.Group("/:instrumentName")
-	instrumentRouter.Use(instrumentController.Auth.AuthenticatedWithUac)
+	instrumentRouter.Use(
+		instrumentController.Auth.AuthenticatedWithUac,
+		instrumentController.checkMaintenance,
+		instrumentController.inFieldPeriod,
+	)
 	{
 		instrumentRouter.GET("/", instrumentController.openCase)
 		// Example path /dst2101a/resources/js/jskdjasjdlkasjld.js
@@ -49,6 +84,28 @@ func (instrumentController *InstrumentController) AddRoutes(httpRouter *gin.Engi
 	httpRouter.GET("/:instrumentName/logout", instrumentController.logoutEndpoint)
 }
 
+func (instrumentController *InstrumentController) checkMaintenance(context *gin.Context) {
+	checkMaintenance(context, instrumentController.Maintenance, instrumentController.Logger, instrumentController.LanguageManager)
+}
+
+// inFieldPeriod stops respondents already logged in from carrying on with an
+// instrument once it has closed
+func (instrumentController *InstrumentController) inFieldPeriod(context *gin.Context) {
+	if instrumentController.FieldPeriods == nil {
+		return
+	}
+	instrumentName := context.Param("instrumentName")
+	status, fieldPeriod := instrumentController.FieldPeriods.Check(instrumentName, time.Now())
+	if status == fieldperiod.OPEN {
+		return
+	}
+	instrumentController.Logger.Info("Instrument outside of field period", append(utils.GetRequestSource(context),
+		zap.String("Reason", authenticate.FieldPeriodReason(status)),
+		zap.String("InstrumentName", instrumentName),
+	)...)
+	authenticate.OutsideFieldPeriod(context, instrumentController.LanguageManager.IsWelsh(context), status, fieldPeriod)
+}
+
 func (instrumentController *InstrumentController) instrumentAuth(context *gin.Context) (*authenticate.UACClaims, error) {
 	session := sessions.DefaultMany(context, "user_session")
 	jwtToken := session.Get(authenticate.JWT_TOKEN_KEY)
@@ -76,49 +133,89 @@ func (instrumentController *InstrumentController) openCase(context *gin.Context)
 	if err != nil {
 		return
 	}
-	resp, err := http.PostForm(
-		fmt.Sprintf("%s/%s/default.aspx", instrumentController.CatiUrl, uacClaim.UacInfo.InstrumentName),
-		blaise.CasePayload(uacClaim.UacInfo.CaseID, instrumentController.LanguageManager.IsWelsh(context)).Form(),
-	)
+	instrumentName := uacClaim.UacInfo.InstrumentName
+	launchResponse, err := instrumentController.Launcher.Launch(context.Request.Context(),
+		instrumentController.catiUrl(instrumentName), instrumentName,
+		blaise.CasePayload(uacClaim.UacInfo.CaseID, instrumentController.LanguageManager.IsWelsh(context)))
 	if err != nil {
-		instrumentController.Logger.Error("Error launching blaise study", append(uacClaim.LogFields(), zap.Error(err))...)
-		InternalServerError(context, instrumentController.LanguageManager.IsWelsh(context))
+		instrumentController.launchFailed(context, uacClaim, err)
 		return
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		instrumentController.Logger.Error("Error launching blaise study, cannot read response body",
-			append(uacClaim.LogFields(), zap.Error(err))...)
-		InternalServerError(context, instrumentController.LanguageManager.IsWelsh(context))
-		return
+	body := launchResponse.Body
+	if getContentType(launchResponse.ContentType) == "text/html" {
+		// Reading the page back from memory cannot fail
+		body, _ = ioutil.ReadAll(InjectScript(bytes.NewReader(body), CHECK_SESSION_SCRIPT))
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		instrumentController.Logger.Error("Error launching blaise study, invalid status code",
-			append(uacClaim.LogFields(),
-				zap.Int("RespStatusCode", resp.StatusCode),
-				zap.ByteString("RespBody", body),
-			)...)
-		InternalServerError(context, instrumentController.LanguageManager.IsWelsh(context))
-		return
-	}
+	context.Data(http.StatusOK, launchResponse.ContentType, body)
+}
 
-	if getContentType(resp) == "text/html" {
-		var buf bytes.Buffer
-		injectedBody, err := InjectScript(body)
-		if err == nil {
-			html.Render(&buf, injectedBody)
-			body = buf.Bytes()
-		} else {
-			instrumentController.Logger.Error("Error injecting check-session script",
-				append(uacClaim.LogFields(), zap.Error(err))...)
-		}
+// launchFailed tells the respondent their case could not be opened, with a
+// page to suit why CATI did not open it
+func (instrumentController *InstrumentController) launchFailed(context *gin.Context, uacClaim *authenticate.UACClaims, err error) {
+	var (
+		timeoutError     *upstream.TimeoutError
+		unavailableError *upstream.UnavailableError
+		notFoundError    *upstream.NotFoundError
+		redirectError    *upstream.RedirectError
+		malformedError   *upstream.MalformedResponseError
+	)
+	welsh := instrumentController.LanguageManager.IsWelsh(context)
+	switch {
+	case context.Request.Context().Err() != nil:
+		instrumentController.Logger.Info("Error launching blaise study", append(uacClaim.LogFields(),
+			zap.String("Reason", "Request cancelled"),
+			zap.Error(err),
+		)...)
+		context.AbortWithStatus(http.StatusRequestTimeout)
+	case errors.As(err, &timeoutError):
+		instrumentController.Logger.Error("Error launching blaise study", append(uacClaim.LogFields(),
+			zap.String("Reason", "CATI timed out"),
+			zap.Error(err),
+		)...)
+		context.HTML(http.StatusServiceUnavailable, "service_busy.tmpl", gin.H{"welsh": welsh})
+		context.Abort()
+	case errors.As(err, &unavailableError):
+		instrumentController.Logger.Error("Error launching blaise study", append(uacClaim.LogFields(),
+			zap.String("Reason", "CATI unavailable"),
+			zap.Int("RespStatusCode", unavailableError.StatusCode),
+			zap.String("RespBody", unavailableError.Body),
+			zap.Error(err),
+		)...)
+		ServerError(context, http.StatusBadGateway, welsh)
+	case errors.As(err, &notFoundError):
+		instrumentController.Logger.Error("Error launching blaise study", append(uacClaim.LogFields(),
+			zap.String("Reason", "Instrument not found in CATI"),
+			zap.Int("RespStatusCode", notFoundError.StatusCode),
+			zap.String("RespBody", notFoundError.Body),
+			zap.Error(err),
+		)...)
+		context.HTML(http.StatusNotFound, "not_live.tmpl", gin.H{"welsh": welsh})
+		context.Abort()
+	case errors.As(err, &redirectError):
+		instrumentController.Logger.Error("Error launching blaise study", append(uacClaim.LogFields(),
+			zap.String("Reason", "CATI redirected"),
+			zap.Int("RespStatusCode", redirectError.StatusCode),
+			zap.String("RespLocation", redirectError.Location),
+			zap.Error(err),
+		)...)
+		ServerError(context, http.StatusBadGateway, welsh)
+	case errors.As(err, &malformedError):
+		instrumentController.Logger.Error("Error launching blaise study", append(uacClaim.LogFields(),
+			zap.String("Reason", "Unexpected response from CATI"),
+			zap.Int("RespStatusCode", malformedError.StatusCode),
+			zap.String("RespBody", malformedError.Body),
+			zap.Error(err),
+		)...)
+		InternalServerError(context, welsh)
+	default:
+		instrumentController.Logger.Error("Error launching blaise study", append(uacClaim.LogFields(),
+			zap.String("Reason", "Could not launch case"),
+			zap.Error(err),
+		)...)
+		InternalServerError(context, welsh)
 	}
-
-	context.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
 }
 
 func (instrumentController *InstrumentController) proxyWithInstrumentAuth(context *gin.Context) {
@@ -167,20 +264,78 @@ func (instrumentController *InstrumentController) startInterviewAuth(context *gi
 }
 
 func (instrumentController *InstrumentController) proxy(context *gin.Context, uacClaim *authenticate.UACClaims) {
-	remote, err := url.Parse(instrumentController.CatiUrl)
+	catiUrl := instrumentController.catiUrl(uacClaim.UacInfo.InstrumentName)
+	proxy, err := instrumentController.reverseProxy(catiUrl)
 	if err != nil {
-		instrumentController.Logger.Error("Could not parse url for proxying", zap.String("URL", instrumentController.CatiUrl))
+		instrumentController.Logger.Error("Could not parse url for proxying", zap.String("URL", catiUrl))
 		InternalServerError(context, instrumentController.LanguageManager.IsWelsh(context))
 		return
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(remote)
+	catiContext, cancel := utils.WithTimeout(context.Request.Context(), instrumentController.CatiTimeout)
+	defer cancel()
+	catiContext = stdcontext.WithValue(catiContext, proxyRequestKey{}, &proxyRequest{context: context, uacClaim: uacClaim})
+	proxy.ServeHTTP(context.Writer, context.Request.WithContext(catiContext))
+}
+
+// reverseProxy returns the proxy to the CATI service at catiUrl, made on
+// first use and shared by every request after
+func (instrumentController *InstrumentController) reverseProxy(catiUrl string) (*httputil.ReverseProxy, error) {
+	instrumentController.proxiesMutex.Lock()
+	defer instrumentController.proxiesMutex.Unlock()
+	if proxy, found := instrumentController.proxies[catiUrl]; found {
+		return proxy, nil
+	}
 
+	remote, err := url.Parse(catiUrl)
+	if err != nil {
+		return nil, err
+	}
+	proxy := httputil.NewSingleHostReverseProxy(remote)
+	proxy.Transport = instrumentController.Transport
 	if instrumentController.Debug {
-		proxy.Transport = &debugTransport{Logger: instrumentController.Logger}
+		proxy.Transport = &debugTransport{Logger: instrumentController.Logger, Transport: instrumentController.Transport}
+	}
+	proxy.ModifyResponse = InjectScriptIntoResponse
+	proxy.ErrorHandler = instrumentController.proxyError
+
+	if instrumentController.proxies == nil {
+		instrumentController.proxies = map[string]*httputil.ReverseProxy{}
+	}
+	instrumentController.proxies[catiUrl] = proxy
+	return proxy, nil
+}
+
+// proxyError shows the respondent the server error page when CATI cannot be
+// reached or does not answer in time
+func (instrumentController *InstrumentController) proxyError(w http.ResponseWriter, req *http.Request, err error) {
+	proxyRequest, found := req.Context().Value(proxyRequestKey{}).(*proxyRequest)
+	if !found {
+		instrumentController.Logger.Error("Error proxying to blaise", zap.String("URL", req.URL.String()), zap.Error(err))
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	context := proxyRequest.context
+	logFields := append(proxyRequest.uacClaim.LogFields(), zap.String("URL", req.URL.String()), zap.Error(err))
+	if context.Request.Context().Err() != nil {
+		instrumentController.Logger.Info("Respondent went away while proxying to blaise", logFields...)
+		context.Abort()
+		return
 	}
 
-	proxy.ServeHTTP(context.Writer, context.Request)
+	status := http.StatusBadGateway
+	if errors.Is(err, stdcontext.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	instrumentController.Logger.Error("Error proxying to blaise", append(logFields, zap.Int("Status", status))...)
+	ServerError(context, status, instrumentController.LanguageManager.IsWelsh(context))
+}
+
+func (instrumentController *InstrumentController) catiUrl(instrumentName string) string {
+	if instrumentController.Serverparks == nil {
+		return instrumentController.CatiUrl
+	}
+	return instrumentController.Serverparks.Resolve(instrumentName).CatiUrl
 }
 
 func (instrumentController *InstrumentController) logoutEndpoint(context *gin.Context) {
@@ -200,7 +355,8 @@ func isAPICall(context *gin.Context) bool {
 }
 
 type debugTransport struct {
-	Logger *zap.Logger
+	Logger    *zap.Logger
+	Transport http.RoundTripper
 }
 
 func (debugTransport *debugTransport) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -209,37 +365,13 @@ func (debugTransport *debugTransport) RoundTrip(r *http.Request) (*http.Response
 		return nil, err
 	}
 	debugTransport.Logger.Debug("Proxy round trip debug", zap.ByteString("RequestDump", b))
-	return http.DefaultTransport.RoundTrip(r)
-}
-
-func InjectScript(body []byte) (*html.Node, error) {
-	fmt.Println("Inject Script")
-	doc, err := html.Parse(bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	var crawler func(*html.Node)
-	crawler = func(node *html.Node) {
-		if node.Type == html.ElementNode && node.Data == "body" {
-			scriptNode := &html.Node{
-				Type: html.ElementNode,
-				Data: "script",
-				Attr: []html.Attribute{
-					{Key: "src", Val: "/assets/js/check-session.js"},
-				},
-			}
-			node.AppendChild(scriptNode)
-			return
-		}
-		for child := node.FirstChild; child != nil; child = child.NextSibling {
-			crawler(child)
-		}
+	if debugTransport.Transport == nil {
+		return http.DefaultTransport.RoundTrip(r)
 	}
-	crawler(doc)
-	return doc, nil
+	return debugTransport.Transport.RoundTrip(r)
 }
 
-func getContentType(resp *http.Response) string {
-	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+func getContentType(header string) string {
+	contentType, _, _ := mime.ParseMediaType(header)
 	return contentType
 }
@@ -10,17 +10,25 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
 	"github.com/ONSdigital/blaise-cawi-portal/authenticate/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/blaise"
+	blaiseMocks "github.com/ONSdigital/blaise-cawi-portal/blaise/mocks"
 	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+	"github.com/ONSdigital/blaise-cawi-portal/fieldperiod"
+	fieldPeriodMocks "github.com/ONSdigital/blaise-cawi-portal/fieldperiod/mocks"
 	languageManagerMocks "github.com/ONSdigital/blaise-cawi-portal/languagemanager/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/serverpark"
+	"github.com/ONSdigital/blaise-cawi-portal/upstream"
 	"github.com/ONSdigital/blaise-cawi-portal/webserver"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"github.com/jarcoal/httpmock"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
@@ -49,6 +57,21 @@ func CreateTestResponseRecorder() *TestResponseRecorder {
 	}
 }
 
+// countingTransport answers every request itself, counting them
+type countingTransport struct {
+	requests int
+}
+
+func (transport *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport.requests++
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("counted")),
+		Header:     http.Header{},
+		Request:    req,
+	}, nil
+}
+
 type ErrReader struct{ Error error }
 
 func (e *ErrReader) Read([]byte) (int, error) {
@@ -66,7 +89,8 @@ var _ = Describe("Open Case", func() {
 		mockAuth             = &mocks.AuthInterface{}
 		mockJWTCrypto        = &mocks.JWTCryptoInterface{}
 		languageManagerMock  = &languageManagerMocks.LanguageManagerInterface{}
-		instrumentController = &webserver.InstrumentController{CatiUrl: catiUrl, HttpClient: &http.Client{}, Auth: mockAuth, JWTCrypto: mockJWTCrypto, LanguageManager: languageManagerMock}
+		launcher             = &blaise.Launcher{Client: &http.Client{}}
+		instrumentController = &webserver.InstrumentController{CatiUrl: catiUrl, Launcher: launcher, Auth: mockAuth, JWTCrypto: mockJWTCrypto, LanguageManager: languageManagerMock}
 		requestBody          io.Reader
 		observedLogs         *observer.ObservedLogs
 		observedZapCore      zapcore.Core
@@ -245,12 +269,13 @@ var _ = Describe("Open Case", func() {
 				httpRouter.ServeHTTP(httpRecorder, req)
 			})
 
-			It("return a 500 error and redirect to the internal server error page", func() {
-				Expect(httpRecorder.Code).To(Equal(http.StatusInternalServerError))
+			It("return a 502 error and redirect to the internal server error page", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadGateway))
 				Expect(httpRecorder.Body.String()).To(ContainSubstring("Sorry, there is a problem with the service"))
 
 				Expect(observedLogs.Len()).To(Equal(1))
-				Expect(observedLogs.All()[0].Message).To(Equal("Error launching blaise study, invalid status code"))
+				Expect(observedLogs.All()[0].Message).To(Equal("Error launching blaise study"))
+				Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("CATI unavailable"))
 				Expect(observedLogs.All()[0].ContextMap()["AuthedCaseID"]).To(Equal(caseID))
 				Expect(observedLogs.All()[0].ContextMap()["AuthedInstrumentName"]).To(Equal(instrumentName))
 				Expect(observedLogs.All()[0].ContextMap()["RespStatusCode"]).To(Equal(int64(500)))
@@ -258,6 +283,41 @@ var _ = Describe("Open Case", func() {
 				Expect(observedLogs.All()[0].Level).To(Equal(zap.ErrorLevel))
 			})
 		})
+
+		Context("Blaise does not respond before the CATI timeout", func() {
+			JustBeforeEach(func() {
+				languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+				httpmock.RegisterResponder("POST", fmt.Sprintf("%s/%s/default.aspx", catiUrl, instrumentName),
+					func(req *http.Request) (*http.Response, error) {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					})
+
+				mockAuth.On("AuthenticatedWithUac", mock.Anything).Return()
+				mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(&authenticate.UACClaims{UacInfo: busapi.UacInfo{
+					InstrumentName: instrumentName,
+					CaseID:         caseID,
+				}}, nil)
+
+				launcher.Timeout = 10 * time.Millisecond
+				httpRecorder = CreateTestResponseRecorder()
+				req, _ := http.NewRequest("GET", fmt.Sprintf("/%s/", instrumentName), nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			AfterEach(func() {
+				launcher.Timeout = 0
+			})
+
+			It("gives up and shows the service busy page", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring("Please try again in a few minutes"))
+				Expect(observedLogs.Len()).To(Equal(1))
+				Expect(observedLogs.All()[0].Message).To(Equal("Error launching blaise study"))
+				Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("CATI timed out"))
+				Expect(observedLogs.All()[0].ContextMap()["error"]).To(ContainSubstring("context deadline exceeded"))
+			})
+		})
 	})
 
 	Describe("Proxy get requests to blaise", func() {
@@ -307,6 +367,31 @@ var _ = Describe("Open Case", func() {
 			})
 		})
 
+		Context("Making a request for a blaise page", func() {
+			JustBeforeEach(func() {
+				mockResponse := httpmock.NewStringResponse(200, "<HTML><Body><p>page</p></Body></HTML>")
+				mockResponse.Header.Set("Content-Type", "text/html; charset=utf-8")
+				httpmock.RegisterResponder("GET", fmt.Sprintf("%s/%s/fwibble", catiUrl, instrumentName),
+					httpmock.ResponderFromResponse(mockResponse))
+
+				mockAuth.On("AuthenticatedWithUac", mock.Anything).Return()
+				mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(&authenticate.UACClaims{UacInfo: busapi.UacInfo{
+					InstrumentName: instrumentName,
+					CaseID:         caseID,
+				}}, nil)
+
+				httpRecorder = CreateTestResponseRecorder()
+				req, _ := http.NewRequest("GET", fmt.Sprintf("/%s/fwibble", instrumentName), nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+			})
+
+			It("Returns the page with an injected check-session script", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpRecorder.Body.String()).To(Equal(
+					`<HTML><Body><p>page</p><script src="/assets/js/check-session.js"></script></Body></HTML>`))
+			})
+		})
+
 		Context("When the get is for a different instrument", func() {
 			JustBeforeEach(func() {
 				languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
@@ -333,6 +418,155 @@ var _ = Describe("Open Case", func() {
 		})
 	})
 
+	Describe("Proxying when blaise cannot be reached", func() {
+		var welsh bool
+
+		JustBeforeEach(func() {
+			languageManagerMock.On("IsWelsh", mock.Anything).Return(welsh)
+			httpmock.RegisterResponder("GET", fmt.Sprintf("%s/%s/fwibble", catiUrl, instrumentName),
+				httpmock.NewErrorResponder(errors.New("connection refused")))
+
+			mockAuth.On("AuthenticatedWithUac", mock.Anything).Return()
+			mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(&authenticate.UACClaims{UacInfo: busapi.UacInfo{
+				InstrumentName: instrumentName,
+				CaseID:         caseID,
+			}}, nil)
+
+			httpRecorder = CreateTestResponseRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/%s/fwibble", instrumentName), nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+		})
+
+		Context("in English", func() {
+			BeforeEach(func() {
+				welsh = false
+			})
+
+			It("shows the server error page", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadGateway))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring("Sorry, there is a problem with the service"))
+
+				Expect(observedLogs.Len()).To(Equal(1))
+				Expect(observedLogs.All()[0].Message).To(Equal("Error proxying to blaise"))
+				Expect(observedLogs.All()[0].ContextMap()["error"]).To(ContainSubstring("connection refused"))
+				Expect(observedLogs.All()[0].ContextMap()["AuthedCaseID"]).To(Equal(caseID))
+				Expect(observedLogs.All()[0].Level).To(Equal(zap.ErrorLevel))
+			})
+		})
+
+		Context("in Welsh", func() {
+			BeforeEach(func() {
+				welsh = true
+			})
+
+			It("shows the Welsh server error page", func() {
+				Expect(httpRecorder.Code).To(Equal(http.StatusBadGateway))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring(`<html lang="cy">`))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring("mae problem gyda"))
+			})
+		})
+
+		Context("and blaise does not respond before the CATI timeout", func() {
+			BeforeEach(func() {
+				welsh = false
+				instrumentController.CatiTimeout = 10 * time.Millisecond
+				httpmock.RegisterResponder("GET", fmt.Sprintf("%s/%s/slow", catiUrl, instrumentName),
+					func(req *http.Request) (*http.Response, error) {
+						<-req.Context().Done()
+						return nil, req.Context().Err()
+					})
+			})
+
+			AfterEach(func() {
+				instrumentController.CatiTimeout = 0
+			})
+
+			It("gives up and shows the server error page", func() {
+				httpRecorder = CreateTestResponseRecorder()
+				req, _ := http.NewRequest("GET", fmt.Sprintf("/%s/slow", instrumentName), nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+
+				Expect(httpRecorder.Code).To(Equal(http.StatusGatewayTimeout))
+				Expect(httpRecorder.Body.String()).To(ContainSubstring("Sorry, there is a problem with the service"))
+			})
+		})
+	})
+
+	Describe("Proxying through the shared transport", func() {
+		var transport *countingTransport
+
+		BeforeEach(func() {
+			// Proxies are made once per CATI service, so use one that has
+			// not been proxied to yet
+			transport = &countingTransport{}
+			instrumentController.Transport = transport
+			instrumentController.CatiUrl = "http://counted"
+			mockAuth.On("AuthenticatedWithUac", mock.Anything).Return()
+			mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(&authenticate.UACClaims{UacInfo: busapi.UacInfo{
+				InstrumentName: instrumentName,
+				CaseID:         caseID,
+			}}, nil)
+		})
+
+		AfterEach(func() {
+			instrumentController.Transport = nil
+			instrumentController.CatiUrl = catiUrl
+		})
+
+		It("sends every request to blaise through it", func() {
+			for i := 0; i < 2; i++ {
+				httpRecorder = CreateTestResponseRecorder()
+				req, _ := http.NewRequest("GET", fmt.Sprintf("/%s/fwibble", instrumentName), nil)
+				httpRouter.ServeHTTP(httpRecorder, req)
+				Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			}
+			Expect(transport.requests).To(Equal(2))
+		})
+	})
+
+	Describe("Routing to the instrument's server park", func() {
+		var routedCatiUrl = "http://cati-two.localhost"
+
+		BeforeEach(func() {
+			instrumentController.Serverparks = &serverpark.Resolver{
+				Default: serverpark.Route{CatiUrl: catiUrl},
+				Routes:  map[string]serverpark.Route{"foo*": {CatiUrl: routedCatiUrl}},
+			}
+			languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+			mockAuth.On("AuthenticatedWithUac", mock.Anything).Return()
+			mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(&authenticate.UACClaims{UacInfo: busapi.UacInfo{
+				InstrumentName: instrumentName,
+				CaseID:         caseID,
+			}}, nil)
+			httpmock.RegisterResponder("POST", fmt.Sprintf("%s/%s/default.aspx", routedCatiUrl, instrumentName),
+				httpmock.NewStringResponder(200, "routed case"))
+			httpmock.RegisterResponder("GET", fmt.Sprintf("%s/%s/fwibble", routedCatiUrl, instrumentName),
+				httpmock.NewStringResponder(200, "routed resource"))
+		})
+
+		AfterEach(func() {
+			instrumentController.Serverparks = nil
+		})
+
+		It("opens the case on the instrument's CATI service", func() {
+			httpRecorder = CreateTestResponseRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/%s/", instrumentName), nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal("routed case"))
+		})
+
+		It("proxies to the instrument's CATI service", func() {
+			httpRecorder = CreateTestResponseRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/%s/fwibble", instrumentName), nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal("routed resource"))
+		})
+	})
+
 	Describe("Proxy post requests to blaise", func() {
 		Context("Making a request for a blaise resource posts proxied to the blaise server", func() {
 			JustBeforeEach(func() {
@@ -460,3 +694,204 @@ var _ = Describe("GET /:instrumentName/logout", func() {
 		mockAuth.AssertNumberOfCalls(GinkgoT(), "Logout", 1)
 	})
 })
+
+var _ = Describe("Instrument field periods", func() {
+	var (
+		httpRouter           *gin.Engine
+		httpRecorder         *httptest.ResponseRecorder
+		mockAuth             *mocks.AuthInterface
+		mockJWTCrypto        *mocks.JWTCryptoInterface
+		mockFieldPeriods     *fieldPeriodMocks.FieldPeriodsInterface
+		languageManagerMock  *languageManagerMocks.LanguageManagerInterface
+		instrumentController *webserver.InstrumentController
+		observedLogs         *observer.ObservedLogs
+		observedZapCore      zapcore.Core
+		status               fieldperiod.Status
+		authenticated        bool
+		opens                = time.Date(2021, 6, 1, 0, 0, 0, 0, fieldperiod.Location)
+	)
+
+	BeforeEach(func() {
+		observedZapCore, observedLogs = observer.New(zap.InfoLevel)
+		authenticated = true
+		mockAuth = &mocks.AuthInterface{}
+		mockAuth.On("AuthenticatedWithUac", mock.Anything).Run(func(args mock.Arguments) {
+			if !authenticated {
+				args.Get(0).(*gin.Context).AbortWithStatus(http.StatusUnauthorized)
+			}
+		}).Return()
+		mockJWTCrypto = &mocks.JWTCryptoInterface{}
+		mockFieldPeriods = &fieldPeriodMocks.FieldPeriodsInterface{}
+		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
+		languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+		instrumentController = &webserver.InstrumentController{
+			Auth:            mockAuth,
+			JWTCrypto:       mockJWTCrypto,
+			Logger:          zap.New(observedZapCore),
+			LanguageManager: languageManagerMock,
+			FieldPeriods:    mockFieldPeriods,
+		}
+
+		httpRouter = gin.Default()
+		store := cookie.NewStore([]byte("secret"))
+		httpRouter.Use(sessions.SessionsMany([]string{"session", "user_session", "session_validation", "language_session"}, store))
+		httpRouter.SetFuncMap(template.FuncMap{
+			"WrapWelsh": webserver.WrapWelsh,
+		})
+		httpRouter.LoadHTMLGlob("../templates/*")
+		instrumentController.AddRoutes(httpRouter)
+	})
+
+	JustBeforeEach(func() {
+		mockFieldPeriods.On("Check", "foobar", mock.Anything).Return(status, fieldperiod.FieldPeriod{Opens: opens})
+		httpRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/foobar/resources/js/app.js", nil)
+		httpRouter.ServeHTTP(httpRecorder, req)
+	})
+
+	Context("when the instrument has closed", func() {
+		BeforeEach(func() {
+			status = fieldperiod.CLOSED
+		})
+
+		It("shows the closed page once the respondent is authenticated", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring("This study has closed"))
+			mockAuth.AssertCalled(GinkgoT(), "AuthenticatedWithUac", mock.Anything)
+
+			Expect(observedLogs.Len()).To(Equal(1))
+			Expect(observedLogs.All()[0].Message).To(Equal("Instrument outside of field period"))
+			Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal("Study closed"))
+			Expect(observedLogs.All()[0].ContextMap()["InstrumentName"]).To(Equal("foobar"))
+		})
+	})
+
+	Context("when the instrument is not open yet", func() {
+		BeforeEach(func() {
+			status = fieldperiod.NOT_OPEN
+		})
+
+		It("shows when the instrument opens", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusForbidden))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring("The study opens on 1 June 2021"))
+		})
+	})
+
+	Context("when the instrument is open", func() {
+		BeforeEach(func() {
+			status = fieldperiod.OPEN
+			languageManagerMock.On("LanguageError", mock.Anything, mock.Anything).Return("")
+			mockAuth.On("NotAuthWithError", mock.Anything, mock.Anything).Return()
+			mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(nil, errors.New("No JWT"))
+		})
+
+		It("goes on to the instrument", func() {
+			mockJWTCrypto.AssertCalled(GinkgoT(), "DecryptJWT", mock.Anything)
+		})
+	})
+
+	Context("when the respondent is not authenticated", func() {
+		BeforeEach(func() {
+			status = fieldperiod.CLOSED
+			authenticated = false
+		})
+
+		It("does not say anything about the instrument", func() {
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+			mockFieldPeriods.AssertNotCalled(GinkgoT(), "Check", mock.Anything, mock.Anything)
+		})
+	})
+})
+
+var _ = Describe("Launch failures", func() {
+	var (
+		httpRouter           *gin.Engine
+		httpRecorder         *httptest.ResponseRecorder
+		mockAuth             *mocks.AuthInterface
+		mockJWTCrypto        *mocks.JWTCryptoInterface
+		mockLauncher         *blaiseMocks.LauncherInterface
+		languageManagerMock  *languageManagerMocks.LanguageManagerInterface
+		instrumentController *webserver.InstrumentController
+		observedLogs         *observer.ObservedLogs
+		observedZapCore      zapcore.Core
+	)
+
+	BeforeEach(func() {
+		observedZapCore, observedLogs = observer.New(zap.InfoLevel)
+		mockAuth = &mocks.AuthInterface{}
+		mockAuth.On("AuthenticatedWithUac", mock.Anything).Return()
+		mockJWTCrypto = &mocks.JWTCryptoInterface{}
+		mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(&authenticate.UACClaims{UacInfo: busapi.UacInfo{
+			InstrumentName: "foobar",
+			CaseID:         "fizzbuzz",
+		}}, nil)
+		mockLauncher = &blaiseMocks.LauncherInterface{}
+		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
+		instrumentController = &webserver.InstrumentController{
+			CatiUrl:         "http://localhost",
+			Auth:            mockAuth,
+			JWTCrypto:       mockJWTCrypto,
+			Launcher:        mockLauncher,
+			Logger:          zap.New(observedZapCore),
+			LanguageManager: languageManagerMock,
+		}
+
+		httpRouter = gin.Default()
+		store := cookie.NewStore([]byte("secret"))
+		httpRouter.Use(sessions.SessionsMany([]string{"session", "user_session", "session_validation", "language_session"}, store))
+		httpRouter.SetFuncMap(template.FuncMap{
+			"WrapWelsh": webserver.WrapWelsh,
+		})
+		httpRouter.LoadHTMLGlob("../templates/*")
+		instrumentController.AddRoutes(httpRouter)
+	})
+
+	DescribeTable("show the respondent why their case could not be opened",
+		func(launchErr error, welsh bool, status int, page, reason string) {
+			languageManagerMock.On("IsWelsh", mock.Anything).Return(welsh)
+			mockLauncher.On("Launch", mock.Anything, "http://localhost", "foobar", blaise.CasePayload("fizzbuzz", welsh)).
+				Return(nil, launchErr)
+
+			httpRecorder = httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/foobar/", nil)
+			httpRouter.ServeHTTP(httpRecorder, req)
+
+			Expect(httpRecorder.Code).To(Equal(status))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring(page))
+			Expect(observedLogs.Len()).To(Equal(1))
+			Expect(observedLogs.All()[0].Message).To(Equal("Error launching blaise study"))
+			Expect(observedLogs.All()[0].ContextMap()["Reason"]).To(Equal(reason))
+			Expect(observedLogs.All()[0].ContextMap()["AuthedCaseID"]).To(Equal("fizzbuzz"))
+		},
+		Entry("when CATI times out", &upstream.TimeoutError{Upstream: blaise.UPSTREAM, Err: errors.New("deadline exceeded")}, false,
+			http.StatusServiceUnavailable, "Please try again in a few minutes", "CATI timed out"),
+		Entry("when CATI times out, in Welsh", &upstream.TimeoutError{Upstream: blaise.UPSTREAM, Err: errors.New("deadline exceeded")}, true,
+			http.StatusServiceUnavailable, "Rhowch gynnig arall arni mewn ychydig funudau", "CATI timed out"),
+		Entry("when CATI is unavailable", &upstream.UnavailableError{Upstream: blaise.UPSTREAM, Err: errors.New("connection refused")}, false,
+			http.StatusBadGateway, "Sorry, there is a problem with the service", "CATI unavailable"),
+		Entry("when CATI does not have the instrument", &upstream.NotFoundError{Upstream: blaise.UPSTREAM, Response: upstream.Response{StatusCode: 404}}, false,
+			http.StatusNotFound, "The study is currently unavailable", "Instrument not found in CATI"),
+		Entry("when CATI does not have the instrument, in Welsh", &upstream.NotFoundError{Upstream: blaise.UPSTREAM, Response: upstream.Response{StatusCode: 404}}, true,
+			http.StatusNotFound, "ar gael ar hyn o bryd", "Instrument not found in CATI"),
+		Entry("when CATI redirects", &upstream.RedirectError{Upstream: blaise.UPSTREAM, Response: upstream.Response{StatusCode: 302}, Location: "/login"}, false,
+			http.StatusBadGateway, "Sorry, there is a problem with the service", "CATI redirected"),
+		Entry("when CATI responds unexpectedly", &upstream.MalformedResponseError{Upstream: blaise.UPSTREAM, Response: upstream.Response{StatusCode: 400}}, false,
+			http.StatusInternalServerError, "Sorry, there is a problem with the service", "Unexpected response from CATI"),
+		Entry("when the launch fails some other way", errors.New("bad url"), false,
+			http.StatusInternalServerError, "Sorry, there is a problem with the service", "Could not launch case"),
+	)
+
+	It("passes the page CATI opened the case with on", func() {
+		languageManagerMock.On("IsWelsh", mock.Anything).Return(false)
+		mockLauncher.On("Launch", mock.Anything, "http://localhost", "foobar", blaise.CasePayload("fizzbuzz", false)).
+			Return(&blaise.LaunchResponse{ContentType: "application/json", Body: []byte(`{"case":"fizzbuzz"}`)}, nil)
+
+		httpRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/foobar/", nil)
+		httpRouter.ServeHTTP(httpRecorder, req)
+
+		Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+		Expect(httpRecorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(httpRecorder.Body.String()).To(Equal(`{"case":"fizzbuzz"}`))
+	})
+})
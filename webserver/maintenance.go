@@ -0,0 +1,57 @@
+package webserver
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/fieldperiod"
+	"github.com/ONSdigital/blaise-cawi-portal/languagemanager"
+	"github.com/ONSdigital/blaise-cawi-portal/maintenance"
+	"github.com/ONSdigital/blaise-cawi-portal/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// checkMaintenance shows the maintenance page when the instrument in the path,
+// or everything when there is no instrument, is under maintenance
+func checkMaintenance(context *gin.Context, maintenanceMode maintenance.MaintenanceInterface, logger *zap.Logger,
+	languageManager languagemanager.LanguageManagerInterface) {
+	if maintenanceMode == nil {
+		return
+	}
+	instrumentName := context.Param("instrumentName")
+	underMaintenance, window := maintenanceMode.Check(instrumentName)
+	if !underMaintenance {
+		return
+	}
+	logger.Info("Under maintenance", append(utils.GetRequestSource(context),
+		zap.String("InstrumentName", instrumentName),
+		zap.String("Path", context.Request.URL.Path),
+	)...)
+	UnderMaintenance(context, languageManager.IsWelsh(context), window)
+}
+
+func UnderMaintenance(context *gin.Context, welsh bool, window maintenance.Window) {
+	var until string
+	if window.Until != nil {
+		if retryAfter := time.Until(*window.Until); retryAfter > 0 {
+			context.Header("Retry-After", fmt.Sprint(int64(math.Ceil(retryAfter.Seconds()))))
+		}
+		until = formatUntil(*window.Until, welsh)
+	}
+	context.HTML(http.StatusServiceUnavailable, "maintenance.tmpl", gin.H{
+		"until": until,
+		"welsh": welsh,
+	})
+	context.Abort()
+}
+
+func formatUntil(until time.Time, welsh bool) string {
+	clock := until.In(fieldperiod.Location).Format("15:04")
+	if welsh {
+		return fmt.Sprintf("%s ar %s", clock, fieldperiod.FormatDate(until, true))
+	}
+	return fmt.Sprintf("%s on %s", clock, fieldperiod.FormatDate(until, false))
+}
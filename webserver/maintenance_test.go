@@ -0,0 +1,184 @@
+package webserver_test
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+	"github.com/ONSdigital/blaise-cawi-portal/authenticate/mocks"
+	languageManagerMocks "github.com/ONSdigital/blaise-cawi-portal/languagemanager/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/maintenance"
+	maintenanceMocks "github.com/ONSdigital/blaise-cawi-portal/maintenance/mocks"
+	"github.com/ONSdigital/blaise-cawi-portal/webserver"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	csrf "github.com/srbry/gin-csrf"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Maintenance mode", func() {
+	var (
+		httpRouter          *gin.Engine
+		httpRecorder        *httptest.ResponseRecorder
+		mockAuth            *mocks.AuthInterface
+		mockJWTCrypto       *mocks.JWTCryptoInterface
+		mockMaintenance     *maintenanceMocks.MaintenanceInterface
+		languageManagerMock *languageManagerMocks.LanguageManagerInterface
+		observedLogs        *observer.ObservedLogs
+		observedZapCore     zapcore.Core
+		welsh               bool
+		authenticated       bool
+	)
+
+	BeforeEach(func() {
+		welsh = false
+		authenticated = true
+		observedZapCore, observedLogs = observer.New(zap.InfoLevel)
+		logger := zap.New(observedZapCore)
+		mockAuth = &mocks.AuthInterface{}
+		mockAuth.On("AuthenticatedWithUac", mock.Anything).Run(func(args mock.Arguments) {
+			if !authenticated {
+				args.Get(0).(*gin.Context).AbortWithStatus(http.StatusUnauthorized)
+			}
+		}).Return()
+		mockJWTCrypto = &mocks.JWTCryptoInterface{}
+		mockMaintenance = &maintenanceMocks.MaintenanceInterface{}
+		languageManagerMock = &languageManagerMocks.LanguageManagerInterface{}
+		languageManagerMock.On("IsWelsh", mock.Anything).Return(func(*gin.Context) bool { return welsh })
+
+		httpRouter = gin.Default()
+		store := cookie.NewStore([]byte("secret"))
+		httpRouter.Use(sessions.SessionsMany([]string{"session", "user_session", "session_validation", "language_session"}, store))
+		httpRouter.SetFuncMap(template.FuncMap{
+			"WrapWelsh": webserver.WrapWelsh,
+		})
+		httpRouter.LoadHTMLGlob("../templates/*")
+
+		authController := &webserver.AuthController{
+			Auth:            mockAuth,
+			Logger:          logger,
+			CSRFManager:     &csrf.DefaultCSRFManager{Secret: "fwibble", SessionName: "session"},
+			LanguageManager: languageManagerMock,
+			Maintenance:     mockMaintenance,
+		}
+		authController.AddRoutes(httpRouter)
+		instrumentController := &webserver.InstrumentController{
+			Auth:            mockAuth,
+			JWTCrypto:       mockJWTCrypto,
+			Logger:          logger,
+			LanguageManager: languageManagerMock,
+			Maintenance:     mockMaintenance,
+		}
+		instrumentController.AddRoutes(httpRouter)
+		healthController := &webserver.HealthController{}
+		healthController.AddRoutes(httpRouter)
+	})
+
+	request := func(path string) {
+		httpRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		httpRouter.ServeHTTP(httpRecorder, req)
+	}
+
+	Context("when everything is under maintenance", func() {
+		var until time.Time
+
+		BeforeEach(func() {
+			until = time.Now().Add(time.Hour)
+			mockMaintenance.On("Check", mock.Anything).Return(true, maintenance.Window{Enabled: true, Until: &until})
+		})
+
+		It("shows the maintenance page instead of logging in", func() {
+			request("/auth/login")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring("This service is temporarily unavailable"))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring("We expect it to be available again by"))
+			Expect(httpRecorder.Header().Get("Retry-After")).ToNot(BeEmpty())
+			mockAuth.AssertNotCalled(GinkgoT(), "HasSession", mock.Anything)
+
+			Expect(observedLogs.Len()).To(Equal(1))
+			Expect(observedLogs.All()[0].Message).To(Equal("Under maintenance"))
+			Expect(observedLogs.All()[0].ContextMap()["Path"]).To(Equal("/auth/login"))
+		})
+
+		It("shows the maintenance page in Welsh", func() {
+			welsh = true
+			request("/auth/login")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring("Rydym yn gwneud gwaith cynnal a chadw ar y gwasanaeth hwn."))
+		})
+
+		It("shows the maintenance page instead of the instrument", func() {
+			request("/foobar/")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusServiceUnavailable))
+			mockJWTCrypto.AssertNotCalled(GinkgoT(), "DecryptJWT", mock.Anything)
+		})
+
+		It("asks respondents who are not logged in to log in first", func() {
+			authenticated = false
+			request("/foobar/")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("lets respondents log out", func() {
+			mockAuth.On("Logout", mock.Anything, mock.Anything).Return()
+			request("/auth/logout")
+
+			mockAuth.AssertCalled(GinkgoT(), "Logout", mock.Anything, mock.Anything)
+			mockMaintenance.AssertNotCalled(GinkgoT(), "Check", mock.Anything)
+		})
+
+		It("keeps answering whether respondents are logged in", func() {
+			mockAuth.On("HasSession", mock.Anything).Return(true, &authenticate.UACClaims{})
+			request("/auth/logged-in")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			mockMaintenance.AssertNotCalled(GinkgoT(), "Check", mock.Anything)
+		})
+
+		It("keeps reporting healthy", func() {
+			request("/health")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusOK))
+			Expect(httpRecorder.Body.String()).To(Equal(`{"healthy":true}`))
+		})
+	})
+
+	Context("when one instrument is under maintenance", func() {
+		BeforeEach(func() {
+			mockMaintenance.On("Check", "foobar").Return(true, maintenance.Window{Enabled: true})
+			mockMaintenance.On("Check", mock.Anything).Return(false, maintenance.Window{})
+			languageManagerMock.On("LanguageError", mock.Anything, mock.Anything).Return("")
+			mockAuth.On("NotAuthWithError", mock.Anything, mock.Anything).Return()
+			mockJWTCrypto.On("DecryptJWT", mock.Anything).Return(nil, errors.New("No JWT"))
+		})
+
+		It("shows the maintenance page for that instrument", func() {
+			request("/foobar/")
+
+			Expect(httpRecorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(httpRecorder.Body.String()).To(ContainSubstring("Please try again later."))
+			Expect(httpRecorder.Header().Get("Retry-After")).To(BeEmpty())
+		})
+
+		It("lets other instruments through", func() {
+			request("/fizzbuzz/")
+
+			Expect(httpRecorder.Code).ToNot(Equal(http.StatusServiceUnavailable))
+			mockJWTCrypto.AssertCalled(GinkgoT(), "DecryptJWT", mock.Anything)
+		})
+	})
+})
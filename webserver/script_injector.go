@@ -0,0 +1,136 @@
+package webserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"golang.org/x/net/html"
+)
+
+// CHECK_SESSION_SCRIPT is added to Blaise pages so they notice when the
+// respondent's session has ended
+const CHECK_SESSION_SCRIPT = `<script src="/assets/js/check-session.js"></script>`
+
+// scriptInjector copies an HTML page through token by token, adding the
+// script just before the first </body>. Everything else is passed on exactly
+// as it was sent, and once the script is in the rest of the page is copied
+// without being tokenized. Pages without a </body>, such as fragments, are
+// left as they are.
+type scriptInjector struct {
+	tokenizer *html.Tokenizer
+	source    io.Reader
+	script    []byte
+	pending   []byte
+	rest      io.Reader
+	err       error
+}
+
+// InjectScript returns a reader of the page read from body, with script
+// added just before its </body>
+func InjectScript(body io.Reader, script string) io.Reader {
+	return &scriptInjector{
+		tokenizer: html.NewTokenizer(body),
+		source:    body,
+		script:    []byte(script),
+	}
+}
+
+func (injector *scriptInjector) Read(p []byte) (int, error) {
+	for len(injector.pending) == 0 {
+		if injector.rest != nil {
+			return injector.rest.Read(p)
+		}
+		if injector.err != nil {
+			return 0, injector.err
+		}
+		injector.next()
+	}
+	n := copy(p, injector.pending)
+	injector.pending = injector.pending[n:]
+	return n, nil
+}
+
+// next queues up the raw bytes of the next token, which are only valid
+// until the token after is read
+func (injector *scriptInjector) next() {
+	tokenType := injector.tokenizer.Next()
+	injector.pending = injector.tokenizer.Raw()
+	switch tokenType {
+	case html.ErrorToken:
+		injector.err = injector.tokenizer.Err()
+	case html.EndTagToken:
+		if isBodyEndTag(injector.pending) {
+			injector.pending = append(append([]byte{}, injector.script...), injector.pending...)
+			injector.rest = io.MultiReader(bytes.NewReader(injector.tokenizer.Buffered()), injector.source)
+		}
+	}
+}
+
+// isBodyEndTag reads the tag name from the raw end tag, as the tokenizer's
+// TagName lowercases it in place, which would change the page
+func isBodyEndTag(raw []byte) bool {
+	if len(raw) < len("</body>") || !bytes.EqualFold(raw[2:6], []byte("body")) {
+		return false
+	}
+	switch raw[6] {
+	case ' ', '\t', '\n', '\f', '\r', '/', '>':
+		return true
+	}
+	return false
+}
+
+// InjectScriptIntoResponse is a ReverseProxy.ModifyResponse hook adding
+// CHECK_SESSION_SCRIPT to HTML pages as they are streamed to the respondent.
+// Gzipped pages are unzipped to do so; pages in other encodings are left
+// alone.
+func InjectScriptIntoResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK || getContentType(resp.Header.Get("Content-Type")) != "text/html" {
+		return nil
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return nil
+	}
+
+	body := resp.Body
+	switch resp.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		body = &gzipBody{Reader: gzipReader, body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+	default:
+		return nil
+	}
+
+	resp.Body = &injectedBody{Reader: InjectScript(body, CHECK_SESSION_SCRIPT), body: body}
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return nil
+}
+
+type injectedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (injectedBody *injectedBody) Close() error {
+	return injectedBody.body.Close()
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (gzipBody *gzipBody) Close() error {
+	gzipBody.Reader.Close()
+	return gzipBody.body.Close()
+}
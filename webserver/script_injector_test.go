@@ -0,0 +1,174 @@
+package webserver_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ONSdigital/blaise-cawi-portal/webserver"
+	"golang.org/x/net/html"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+const script = webserver.CHECK_SESSION_SCRIPT
+
+func injectScript(page string) string {
+	injected, err := ioutil.ReadAll(webserver.InjectScript(iotest.OneByteReader(strings.NewReader(page)), script))
+	Expect(err).ToNot(HaveOccurred())
+	return string(injected)
+}
+
+var _ = Describe("InjectScript", func() {
+	DescribeTable("adds the script before </body>, keeping the page as it was",
+		func(page, injected string) {
+			Expect(injectScript(page)).To(Equal(injected))
+		},
+		Entry("in an empty body",
+			"<html><head></head><body></body></html>",
+			"<html><head></head><body>"+script+"</body></html>"),
+		Entry("without normalising the markup",
+			"<!DOCTYPE html>\n<HTML lang=cy><body class='x'>\n<p>Un &amp; dau&nbsp;<br>\n</BODY >\n</html>\n",
+			"<!DOCTYPE html>\n<HTML lang=cy><body class='x'>\n<p>Un &amp; dau&nbsp;<br>\n"+script+"</BODY >\n</html>\n"),
+		Entry("ignoring </body> in scripts and comments",
+			"<body><script>var end = '</body>';</script><!-- </body> --></body>",
+			"<body><script>var end = '</body>';</script><!-- </body> -->"+script+"</body>"),
+		Entry("only before the body's own end tag",
+			"<body><p></bodyguard></body>",
+			"<body><p></bodyguard>"+script+"</body>"),
+		Entry("only once",
+			"<body></body></body>",
+			"<body>"+script+"</body></body>"),
+		Entry("with nothing in a fragment",
+			"<div>no body here</div>",
+			"<div>no body here</div>"),
+		Entry("with nothing in a broken page",
+			"<body><p class=\"unterminated",
+			"<body><p class=\"unterminated"),
+		Entry("with nothing in an empty page", "", ""),
+	)
+
+	It("passes on errors reading the page", func() {
+		_, err := ioutil.ReadAll(webserver.InjectScript(iotest.TimeoutReader(strings.NewReader("<body>")), script))
+		Expect(err).To(Equal(iotest.ErrTimeout))
+	})
+})
+
+var _ = Describe("InjectScriptIntoResponse", func() {
+	var resp *http.Response
+
+	newResponse := func(contentType, body string) *http.Response {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": {contentType}, "Content-Length": {fmt.Sprint(len(body))}},
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       &http.Request{Method: http.MethodGet},
+		}
+	}
+
+	readBody := func() string {
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.Body.Close()).To(Succeed())
+		return string(body)
+	}
+
+	It("injects the script into HTML pages", func() {
+		resp = newResponse("text/html; charset=utf-8", "<body></body>")
+		Expect(webserver.InjectScriptIntoResponse(resp)).To(Succeed())
+		Expect(readBody()).To(Equal("<body>" + script + "</body>"))
+		Expect(resp.Header.Get("Content-Length")).To(BeEmpty())
+		Expect(resp.ContentLength).To(Equal(int64(-1)))
+	})
+
+	It("unzips gzipped pages to inject the script", func() {
+		var zipped bytes.Buffer
+		gzipWriter := gzip.NewWriter(&zipped)
+		gzipWriter.Write([]byte("<body></body>"))
+		gzipWriter.Close()
+		resp = newResponse("text/html", zipped.String())
+		resp.Header.Set("Content-Encoding", "gzip")
+
+		Expect(webserver.InjectScriptIntoResponse(resp)).To(Succeed())
+		Expect(readBody()).To(Equal("<body>" + script + "</body>"))
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+	})
+
+	DescribeTable("leaves other responses alone",
+		func(modify func(*http.Response)) {
+			resp = newResponse("text/html", "<body></body>")
+			modify(resp)
+			Expect(webserver.InjectScriptIntoResponse(resp)).To(Succeed())
+			Expect(readBody()).To(Equal("<body></body>"))
+			Expect(resp.Header.Get("Content-Length")).To(Equal("13"))
+		},
+		Entry("that are not HTML", func(resp *http.Response) { resp.Header.Set("Content-Type", "application/json") }),
+		Entry("that are not OK", func(resp *http.Response) { resp.StatusCode = http.StatusNotFound }),
+		Entry("to HEAD requests", func(resp *http.Response) { resp.Request.Method = http.MethodHead }),
+		Entry("in other encodings", func(resp *http.Response) { resp.Header.Set("Content-Encoding", "br") }),
+	)
+})
+
+// blaisePage is an HTML page the shape of a Blaise questionnaire page, with
+// scripts and styles in the head and questions of about 1KB each
+func blaisePage(questions int) []byte {
+	var page bytes.Buffer
+	page.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&page, "<script src=\"/dst2106a/resources/js/bundle-%d.js\"></script>\n", i)
+		fmt.Fprintf(&page, "<link rel=\"stylesheet\" href=\"/dst2106a/resources/css/style-%d.css\">\n", i)
+	}
+	page.WriteString("<script>window.blaise = {page: '</body>', questions: []};</script>\n</head>\n<body>\n<form id=\"form\" method=\"post\">\n")
+	for i := 0; i < questions; i++ {
+		fmt.Fprintf(&page, "<div class=\"question\" id=\"q%d\"><!-- question %d -->\n", i, i)
+		fmt.Fprintf(&page, "<label for=\"a%d\">How many people live at this address? &nbsp;(%d)</label>\n", i, i)
+		fmt.Fprintf(&page, "<input type=\"text\" id=\"a%d\" name=\"a%d\" value=\"\" data-validate=\"integer\" aria-describedby=\"h%d\">\n", i, i, i)
+		fmt.Fprintf(&page, "<p id=\"h%d\" class=\"hint\">%s</p>\n</div>\n", i, strings.Repeat("Include everyone who usually lives here. ", 18))
+	}
+	page.WriteString("</form>\n</body>\n</html>\n")
+	return page.Bytes()
+}
+
+func benchmarkInjectScript(b *testing.B, questions int) {
+	page := blaisePage(questions)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := io.Copy(ioutil.Discard, webserver.InjectScript(bytes.NewReader(page), script)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkParseAndRender is the cost of injecting the script by parsing the
+// whole page and rendering it again, for comparison
+func benchmarkParseAndRender(b *testing.B, questions int) {
+	page := blaisePage(questions)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		doc, err := html.Parse(bytes.NewReader(page))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := html.Render(ioutil.Discard, doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInjectScript100KB(b *testing.B)   { benchmarkInjectScript(b, 100) }
+func BenchmarkInjectScript1MB(b *testing.B)     { benchmarkInjectScript(b, 1000) }
+func BenchmarkParseAndRender100KB(b *testing.B) { benchmarkParseAndRender(b, 100) }
+func BenchmarkParseAndRender1MB(b *testing.B)   { benchmarkParseAndRender(b, 1000) }
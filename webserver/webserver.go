@@ -2,15 +2,28 @@ package webserver
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/ONSdigital/blaise-cawi-portal/authenticate"
+	"github.com/ONSdigital/blaise-cawi-portal/blaise"
 	"github.com/ONSdigital/blaise-cawi-portal/blaiserestapi"
 	"github.com/ONSdigital/blaise-cawi-portal/busapi"
+	"github.com/ONSdigital/blaise-cawi-portal/credentials"
+	"github.com/ONSdigital/blaise-cawi-portal/fieldperiod"
+	"github.com/ONSdigital/blaise-cawi-portal/kvstore"
 	"github.com/ONSdigital/blaise-cawi-portal/languagemanager"
+	"github.com/ONSdigital/blaise-cawi-portal/maintenance"
+	"github.com/ONSdigital/blaise-cawi-portal/serverpark"
+	"github.com/ONSdigital/blaise-cawi-portal/sessionregistry"
+	"github.com/ONSdigital/blaise-cawi-portal/throttle"
 	"github.com/ONSdigital/blaise-cawi-portal/utils"
 	"github.com/blendle/zapdriver"
 	"github.com/gin-contrib/secure"
@@ -22,7 +35,6 @@ import (
 	csrf "github.com/srbry/gin-csrf"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"google.golang.org/api/idtoken"
 )
 
 const CDN = "https://cdn.ons.gov.uk"
@@ -40,15 +52,64 @@ type Config struct {
 	SessionSecret    string `required:"true" split_words:"true"`
 	EncryptionSecret string `required:"true" split_words:"true"`
 	CatiUrl          string `required:"true" split_words:"true"`
-	JWTSecret        string `required:"true" split_words:"true"`
+	JWTSecret        string `split_words:"true"`
+	JWTKeys          string `split_words:"true"`
+	JWTKeysFile      string `split_words:"true"`
+	UacHashSecret    string `split_words:"true"`
 	BusUrl           string `required:"true" split_words:"true"`
-	BusClientId      string `required:"true" split_words:"true"`
+	BusClientId      string `split_words:"true"`
 	BlaiseRestApi    string `required:"true" split_words:"true"`
+	LinkTokenSecret  string `split_words:"true"`
+	FieldPeriodsFile string `split_words:"true"`
 	Serverpark       string `default:"gusty"`
+	ServerparksFile  string `split_words:"true"`
 	Port             string `default:"8080"`
-	UacKind          string `default:"uac" split_words:"true"`
+	UacKind          string `default:"both" split_words:"true"`
+	Uac12Checksum    string `default:"none" envconfig:"UAC12_CHECKSUM"`
+	Uac16Checksum    string `default:"none" envconfig:"UAC16_CHECKSUM"`
+	Uac16Alphabet    string `envconfig:"UAC16_ALPHABET"`
+	SessionPolicy    string `default:"evict" split_words:"true"`
 	DevMode          bool   `default:"false" split_words:"true"`
 	Debug            bool   `default:"false"`
+
+	// BusAuth is one of none, bearer (with BusToken) or idtoken (with
+	// BusClientId). See BusCredentials for the default.
+	BusAuth  string `split_words:"true"`
+	BusToken string `split_words:"true"`
+	// BlaiseRestApiAuth is one of none, bearer (with BlaiseRestApiToken) or
+	// idtoken (with BlaiseRestApiAudience)
+	BlaiseRestApiAuth     string `default:"none" split_words:"true"`
+	BlaiseRestApiToken    string `split_words:"true"`
+	BlaiseRestApiAudience string `split_words:"true"`
+
+	ThrottleMaxIpAttempts      int64           `default:"20" split_words:"true"`
+	ThrottleMaxSessionAttempts int64           `default:"5" split_words:"true"`
+	ThrottleAttemptWindow      time.Duration   `default:"15m" split_words:"true"`
+	ThrottleBackoff            []time.Duration `default:"1m,5m,15m,60m" split_words:"true"`
+
+	BusTimeout           time.Duration `default:"10s" split_words:"true"`
+	BlaiseRestApiTimeout time.Duration `default:"10s" split_words:"true"`
+	CatiTimeout          time.Duration `default:"60s" split_words:"true"`
+	BusRetries           int           `default:"2" split_words:"true"`
+	BusRetryBackoff      time.Duration `default:"200ms" split_words:"true"`
+	BusBreakerThreshold  int           `default:"5" split_words:"true"`
+	BusBreakerCooldown   time.Duration `default:"30s" split_words:"true"`
+
+	CatiMaxIdleConns          int           `default:"100" split_words:"true"`
+	CatiMaxIdleConnsPerHost   int           `default:"100" split_words:"true"`
+	CatiIdleConnTimeout       time.Duration `default:"90s" split_words:"true"`
+	CatiDialTimeout           time.Duration `default:"10s" split_words:"true"`
+	CatiTLSHandshakeTimeout   time.Duration `default:"10s" envconfig:"CATI_TLS_HANDSHAKE_TIMEOUT"`
+	CatiResponseHeaderTimeout time.Duration `default:"60s" split_words:"true"`
+
+	InstrumentSettingsCacheTTL         time.Duration `default:"5m" split_words:"true"`
+	InstrumentSettingsNotFoundCacheTTL time.Duration `default:"30s" split_words:"true"`
+	InstrumentSettingsPollInterval     time.Duration `default:"30s" split_words:"true"`
+
+	MaintenanceReloadInterval time.Duration `default:"30s" split_words:"true"`
+
+	SessionMaxLifetime            time.Duration            `split_words:"true"`
+	SessionMaxLifetimeInstruments map[string]time.Duration `split_words:"true"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -96,7 +157,8 @@ func CSRFErrorFunc(csrfManager csrf.CSRFManager, config *Config, logger *zap.Log
 			errorMessage = "Request timed out, please try again"
 		}
 		context.HTML(http.StatusForbidden, "login.tmpl", gin.H{
-			"uac16":      config.UacKind == "uac16",
+			"uac12":      config.UacKind == authenticate.UAC12,
+			"uac16":      config.UacKind == authenticate.UAC16,
 			"info":       errorMessage,
 			"csrf_token": csrfManager.GetToken(context),
 			"welsh":      isWelsh,
@@ -139,6 +201,44 @@ func UserSessionStore(config *Config) (sessions.Store, error) {
 	return store, nil
 }
 
+// KeyValueStore returns the store shared by every instance, for login
+// throttling, recording used login links, the registry of active sessions,
+// maintenance settings and the versions of cached instrument settings. It is
+// backed by the session database, or held in memory when running in DevMode.
+func KeyValueStore(config *Config) kvstore.StoreInterface {
+	if config.DevMode {
+		return kvstore.NewMemoryStore()
+	}
+	return kvstore.NewRedisStore(config.RedisSessionDB)
+}
+
+// BusCredentials returns the credentials for calling BUS. Without BusAuth,
+// BUS is called with an ID token for BusClientId, or in DevMode with no
+// client ID, without credentials, so the portal can run without Google
+// credentials against a stand-in BUS.
+func BusCredentials(ctx context.Context, config *Config) (credentials.CredentialsInterface, error) {
+	busAuth := config.BusAuth
+	if busAuth == "" {
+		busAuth = credentials.AUTH_ID_TOKEN
+		if config.DevMode && config.BusClientId == "" {
+			busAuth = credentials.AUTH_NONE
+		}
+	}
+	return credentials.NewCredentials(ctx, busAuth, config.BusToken, config.BusClientId)
+}
+
+// UacHashSecret is the key for the hash of the UAC carried in sessions. Without
+// UacHashSecret set, a key for hashing UACs alone is derived from
+// EncryptionSecret, so the cookie encryption key is not used for both.
+func UacHashSecret(config *Config) string {
+	if config.UacHashSecret != "" {
+		return config.UacHashSecret
+	}
+	mac := hmac.New(sha256.New, []byte(config.EncryptionSecret))
+	mac.Write([]byte("uac-ref"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func WrapWelsh(welsh bool) gin.H {
 	return gin.H{
 		"welsh": welsh,
@@ -149,13 +249,36 @@ type Server struct {
 	Config *Config
 }
 
+// NewCatiTransport is the connection pool shared by every request to CATI,
+// whether proxied or made by the portal itself
+func NewCatiTransport(config *Config) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   config.CatiDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          config.CatiMaxIdleConns,
+		MaxIdleConnsPerHost:   config.CatiMaxIdleConnsPerHost,
+		IdleConnTimeout:       config.CatiIdleConnTimeout,
+		TLSHandshakeTimeout:   config.CatiTLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.CatiResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
 func (server *Server) SetupRouter() *gin.Engine {
 	logger, err := NewLogger(server.Config)
 	if err != nil {
 		log.Fatalf("Error setting up logger: %s", err)
 	}
 	httpRouter := gin.Default()
-	httpClient := &http.Client{}
+	catiTransport := NewCatiTransport(server.Config)
+	httpClient := &http.Client{Transport: catiTransport}
+	if server.Config.Debug {
+		httpClient.Transport = &debugTransport{Logger: logger, Transport: catiTransport}
+	}
 
 	securityConfig := secure.DefaultConfig()
 	securityConfig.ContentSecurityPolicy = contentSecurityPolicy
@@ -217,35 +340,128 @@ func (server *Server) SetupRouter() *gin.Engine {
 	httpRouter.LoadHTMLGlob("templates/*")
 	httpRouter.Static("/assets", "./assets")
 
-	client, err := idtoken.NewClient(context.Background(), server.Config.BusClientId)
+	busCredentials, err := BusCredentials(context.Background(), server.Config)
 	if err != nil {
-		logger.Fatal("Error creating bus client", zap.Error(err))
+		logger.Fatal("Error creating bus credentials", zap.Error(err))
+	}
+
+	jwtKeySet, err := authenticate.LoadJWTKeySet(
+		server.Config.JWTKeys,
+		server.Config.JWTKeysFile,
+		server.Config.JWTSecret,
+	)
+	if err != nil {
+		logger.Fatal("Error loading JWT signing keys", zap.Error(err))
 	}
 
 	jwtCrypto := &authenticate.JWTCrypto{
-		JWTSecret: server.Config.JWTSecret,
+		KeySet:        jwtKeySet,
+		UacHashSecret: UacHashSecret(server.Config),
 	}
 
-	blaiseRestApi := &blaiserestapi.BlaiseRestApi{
-		BaseUrl:    server.Config.BlaiseRestApi,
+	serverparks, err := serverpark.LoadResolver(server.Config.ServerparksFile, serverpark.Route{
 		Serverpark: server.Config.Serverpark,
-		Client:     &http.Client{},
+		CatiUrl:    server.Config.CatiUrl,
+	})
+	if err != nil {
+		logger.Fatal("Error loading server park routes", zap.Error(err))
+	}
+
+	blaiseRestApiCredentials, err := credentials.NewCredentials(
+		context.Background(),
+		server.Config.BlaiseRestApiAuth,
+		server.Config.BlaiseRestApiToken,
+		server.Config.BlaiseRestApiAudience,
+	)
+	if err != nil {
+		logger.Fatal("Error creating blaise rest api credentials", zap.Error(err))
 	}
 
+	kvStore := KeyValueStore(server.Config)
+
+	blaiseRestApi := blaiserestapi.NewCachingBlaiseRestApi(
+		&blaiserestapi.BlaiseRestApi{
+			BaseUrl:     server.Config.BlaiseRestApi,
+			Serverpark:  server.Config.Serverpark,
+			Serverparks: serverparks,
+			Credentials: blaiseRestApiCredentials,
+			Client:      &http.Client{},
+			Timeout:     server.Config.BlaiseRestApiTimeout,
+		},
+		server.Config.InstrumentSettingsCacheTTL,
+		server.Config.InstrumentSettingsNotFoundCacheTTL,
+		kvStore,
+		server.Config.InstrumentSettingsPollInterval,
+	)
+
 	languageManager := &languagemanager.Manager{SessionName: "language_session"}
 	csrfManager := NewCSRFManager(server.Config, logger, languageManager)
 
+	loginThrottle := &throttle.Throttle{
+		Store:              kvStore,
+		Logger:             logger,
+		SessionName:        "session",
+		MaxIPAttempts:      server.Config.ThrottleMaxIpAttempts,
+		MaxSessionAttempts: server.Config.ThrottleMaxSessionAttempts,
+		AttemptWindow:      server.Config.ThrottleAttemptWindow,
+		Backoff:            server.Config.ThrottleBackoff,
+	}
+
+	uacValidators, err := authenticate.NewUacValidators(
+		server.Config.Uac12Checksum,
+		server.Config.Uac16Checksum,
+		server.Config.Uac16Alphabet,
+	)
+	if err != nil {
+		logger.Fatal("Error configuring UAC validation", zap.Error(err))
+	}
+
+	fieldPeriods, err := fieldperiod.LoadFieldPeriods(server.Config.FieldPeriodsFile)
+	if err != nil {
+		logger.Fatal("Error loading field periods", zap.Error(err))
+	}
+
+	maintenanceMode := maintenance.NewMaintenance(kvStore, logger)
+	go maintenanceMode.Watch(server.Config.MaintenanceReloadInterval)
+
+	sessionRegistry, err := sessionregistry.NewRegistry(kvStore, server.Config.SessionPolicy)
+	if err != nil {
+		logger.Fatal("Error configuring session registry", zap.Error(err))
+	}
+
 	auth := &authenticate.Auth{
 		JWTCrypto:     jwtCrypto,
 		BlaiseRestApi: blaiseRestApi,
 		Logger:        logger,
-		BusApi: &busapi.BusApi{
-			BaseUrl: server.Config.BusUrl,
-			Client:  client,
-		},
+		BusApi: busapi.NewResilientBusApi(
+			&busapi.BusApi{
+				BaseUrl:     server.Config.BusUrl,
+				Credentials: busCredentials,
+				Client:      &http.Client{},
+				Timeout:     server.Config.BusTimeout,
+			},
+			server.Config.BusRetries,
+			server.Config.BusRetryBackoff,
+			server.Config.BusBreakerThreshold,
+			server.Config.BusBreakerCooldown,
+			logger,
+		),
 		UacKind:         server.Config.UacKind,
 		CSRFManager:     csrfManager,
 		LanguageManager: languageManager,
+		Throttle:        loginThrottle,
+		UacValidators:   uacValidators,
+		LinkTokenStore:  kvStore,
+		Sessions:        sessionRegistry,
+		FieldPeriods:    fieldPeriods,
+
+		SessionMaxLifetime:            server.Config.SessionMaxLifetime,
+		InstrumentSessionMaxLifetimes: server.Config.SessionMaxLifetimeInstruments,
+	}
+	if server.Config.LinkTokenSecret != "" {
+		auth.LinkTokens = &authenticate.LinkTokenCrypto{
+			LinkTokenSecret: server.Config.LinkTokenSecret,
+		}
 	}
 
 	authController := &AuthController{
@@ -254,6 +470,7 @@ func (server *Server) SetupRouter() *gin.Engine {
 		UacKind:         server.Config.UacKind,
 		CSRFManager:     csrfManager,
 		LanguageManager: languageManager,
+		Maintenance:     maintenanceMode,
 	}
 
 	securityController := &SecurityController{}
@@ -266,14 +483,20 @@ func (server *Server) SetupRouter() *gin.Engine {
 		JWTCrypto:       jwtCrypto,
 		Logger:          logger,
 		CatiUrl:         server.Config.CatiUrl,
-		HttpClient:      httpClient,
+		Serverparks:     serverparks,
+		Launcher:        &blaise.Launcher{Client: httpClient, Timeout: server.Config.CatiTimeout},
+		Transport:       catiTransport,
+		CatiTimeout:     server.Config.CatiTimeout,
 		LanguageManager: languageManager,
+		FieldPeriods:    fieldPeriods,
+		Maintenance:     maintenanceMode,
+		Debug:           server.Config.Debug,
 	}
 	instrumentController.AddRoutes(httpRouter)
 	healthController := &HealthController{}
 	healthController.AddRoutes(httpRouter)
 
-	httpRouter.GET("/", authController.LoginEndpoint)
+	httpRouter.GET("/", authController.CheckMaintenance, authController.LoginEndpoint)
 
 	httpRouter.Any("/language/:lang", func(context *gin.Context) {
 		if languagemanager.GetLangFromParam(context) == "welsh" {
@@ -0,0 +1,79 @@
+package webserver_test
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/ONSdigital/blaise-cawi-portal/credentials"
+	"github.com/ONSdigital/blaise-cawi-portal/webserver"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BusCredentials", func() {
+	It("calls BUS without credentials in DevMode without a client ID", func() {
+		busCredentials, err := webserver.BusCredentials(context.Background(), &webserver.Config{DevMode: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(busCredentials).To(Equal(credentials.NoCredentials{}))
+	})
+
+	It("needs a client ID for an ID token outside DevMode", func() {
+		_, err := webserver.BusCredentials(context.Background(), &webserver.Config{})
+		Expect(err).To(MatchError("idtoken auth needs an audience"))
+	})
+
+	It("uses the configured auth", func() {
+		busCredentials, err := webserver.BusCredentials(context.Background(), &webserver.Config{
+			DevMode:  true,
+			BusAuth:  credentials.AUTH_BEARER,
+			BusToken: "let-me-in",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(busCredentials).To(Equal(&credentials.BearerToken{Token: "let-me-in"}))
+	})
+})
+
+var _ = Describe("UacHashSecret", func() {
+	It("uses the configured secret", func() {
+		Expect(webserver.UacHashSecret(&webserver.Config{UacHashSecret: "pepper", EncryptionSecret: "salt"})).To(Equal("pepper"))
+	})
+
+	It("derives a key of its own from the encryption secret without one", func() {
+		uacHashSecret := webserver.UacHashSecret(&webserver.Config{EncryptionSecret: "salt"})
+		Expect(uacHashSecret).ToNot(BeEmpty())
+		Expect(uacHashSecret).ToNot(ContainSubstring("salt"))
+		Expect(webserver.UacHashSecret(&webserver.Config{EncryptionSecret: "salt"})).To(Equal(uacHashSecret))
+		Expect(webserver.UacHashSecret(&webserver.Config{EncryptionSecret: "pepper"})).ToNot(Equal(uacHashSecret))
+	})
+})
+
+var _ = Describe("NewCatiTransport", func() {
+	It("tunes the connections to CATI from the config", func() {
+		env := map[string]string{
+			"SESSION_SECRET":               "secret",
+			"ENCRYPTION_SECRET":            "secret",
+			"CATI_URL":                     "http://cati",
+			"BUS_URL":                      "http://bus",
+			"BLAISE_REST_API":              "http://rest",
+			"CATI_MAX_IDLE_CONNS_PER_HOST": "20",
+			"CATI_TLS_HANDSHAKE_TIMEOUT":   "5s",
+		}
+		for key, value := range env {
+			os.Setenv(key, value)
+			defer os.Unsetenv(key)
+		}
+
+		config, err := webserver.LoadConfig()
+		Expect(err).ToNot(HaveOccurred())
+
+		transport := webserver.NewCatiTransport(config)
+		Expect(transport.ForceAttemptHTTP2).To(BeTrue())
+		Expect(transport.MaxIdleConns).To(Equal(100))
+		Expect(transport.MaxIdleConnsPerHost).To(Equal(20))
+		Expect(transport.IdleConnTimeout).To(Equal(90 * time.Second))
+		Expect(transport.TLSHandshakeTimeout).To(Equal(5 * time.Second))
+		Expect(transport.ResponseHeaderTimeout).To(Equal(60 * time.Second))
+	})
+})